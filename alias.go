@@ -0,0 +1,155 @@
+package xflag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// split an alias such as "-n", "--dryrun" or a bare "n" into short and long name
+func splitAlias(alias string) (short, long string) {
+	switch {
+	case strings.HasPrefix(alias, "--"):
+		return "", alias[2:]
+	case strings.HasPrefix(alias, "-"):
+		return alias[1:], ""
+	case len(alias) == 1:
+		return alias, ""
+	default:
+		return "", alias
+	}
+}
+
+// register additional names of the named flag
+func (f *FlagSet) AddAlias(name string, aliases ...string) (err error) {
+	for _, alias := range aliases {
+		err = f.addAlias(name, alias, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// register names of the named flag which are still parsed but print a
+// warning naming the replacement
+func (f *FlagSet) AddDeprecatedAlias(name string, aliases ...string) (err error) {
+	for _, alias := range aliases {
+		err = f.addAlias(name, alias, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FlagSet) addAlias(name, alias string, deprecated bool) (err error) {
+	flag := f.Flag(name)
+	if flag == nil {
+		return Errorf(f, nil, ERROR_UNDEFINED_FLAG, "%s flag is undefined", name)
+	}
+
+	short, long := splitAlias(alias)
+	if short == "" && long == "" || len(short) > 1 {
+		return Errorf(f, flag, 0, "invalid alias: %s", alias)
+	}
+
+	if reserved := f.reservedName(short, long); reserved != "" {
+		return Errorf(f, flag, 0, "reserved flag name used: %s", reserved)
+	}
+
+	if short != "" {
+		if _, has := f.shortFlags[short]; has {
+			return Errorf(f, flag, 0, "short flag redefined: -%s", short)
+		}
+		f.shortFlags[short] = flag
+		alias = "-" + short
+	}
+
+	if long != "" {
+		if other, has := f.longFlags[f.longKey(long)]; has {
+			return Errorf(f, flag, 0, "long flag redefined: --%s conflicts with %s", long, other.name())
+		}
+		f.longFlags[f.longKey(long)] = flag
+		alias = "--" + long
+	}
+
+	if deprecated {
+		flag.DeprecatedAliases = append(flag.DeprecatedAliases, alias)
+	} else {
+		flag.Aliases = append(flag.Aliases, alias)
+	}
+
+	return nil
+}
+
+// return the flag names in the order shown in help and completion,
+// deprecated aliases are not included
+func (flag *Flag) names() (shorts, longs []string) {
+	if flag.Short != "" {
+		shorts = append(shorts, "-"+flag.Short)
+	}
+
+	if flag.Long != "" {
+		longs = append(longs, "--"+flag.Long)
+	}
+
+	for _, alias := range flag.Aliases {
+		if strings.HasPrefix(alias, "--") {
+			longs = append(longs, alias)
+		} else {
+			shorts = append(shorts, alias)
+		}
+	}
+
+	return shorts, longs
+}
+
+// whether name, with dashes, is one of the names of the flag.
+// long names are compared through Normalize of the FlagSet.
+func (flag *Flag) hasName(name string) bool {
+	shorts, longs := flag.names()
+	key := flag.fs.argKey(name)
+
+	for _, names := range [][]string{shorts, longs, flag.DeprecatedAliases} {
+		for _, n := range names {
+			if flag.fs.argKey(n) == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// print a warning when the flag was used by a deprecated alias
+func (f *FlagSet) warnDeprecated(flag *Flag, used string) {
+	var deprecated bool
+	for _, alias := range flag.DeprecatedAliases {
+		if f.argKey(alias) == f.argKey(used) {
+			deprecated = true
+		}
+	}
+
+	if !deprecated {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "warning: %s is deprecated, use %s instead\n", used, flag.name())
+}
+
+// normalize the long name of an argument with dashes
+func (f *FlagSet) argKey(name string) string {
+	if strings.HasPrefix(name, "--") {
+		return "--" + f.longKey(name[2:])
+	}
+	return name
+}
+
+// return the canonical name with dashes, the long one if defined
+func (flag *Flag) name() string {
+	if flag.Long == "" {
+		return "-" + flag.Short
+	}
+	return "--" + flag.Long
+}
@@ -0,0 +1,168 @@
+package xflag
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// built-in flags handled by the FlagSet itself
+
+var (
+	defaultHelpFlags    = []string{"--help"}
+	defaultVersionFlags = []string{"--version"}
+)
+
+// print help with experimental flags
+const helpAllFlag = "--help-all"
+
+// return help flag names, nil if disabled.
+// sub-commands inherit the settings of the nearest ancestor configuring them.
+func (f *FlagSet) helpFlags() []string {
+	for fs := f; fs != nil; fs = fs.parent {
+		if fs.DisableHelp {
+			return nil
+		}
+
+		if len(fs.HelpFlags) > 0 {
+			return fs.HelpFlags
+		}
+	}
+
+	return defaultHelpFlags
+}
+
+// return the nearest FlagSet enabling the version flag, nil if none
+func (f *FlagSet) versionSet() *FlagSet {
+	for fs := f; fs != nil; fs = fs.parent {
+		if fs.EnableVersion {
+			return fs
+		}
+	}
+	return nil
+}
+
+// return version flag names, nil if disabled
+func (f *FlagSet) versionFlags() []string {
+	fs := f.versionSet()
+	if fs == nil {
+		return nil
+	}
+
+	if len(fs.VersionFlags) == 0 {
+		return defaultVersionFlags
+	}
+
+	return fs.VersionFlags
+}
+
+func matchFlagName(names []string, arg string) bool {
+	for _, name := range names {
+		if name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// return the built-in flag name used by short or long, "" if none
+func (f *FlagSet) reservedName(short, long string) string {
+	var helpAll []string
+	if len(f.helpFlags()) > 0 {
+		helpAll = []string{helpAllFlag}
+	}
+
+	var showConfig []string
+	if f.EnableShowConfig {
+		showConfig = []string{showConfigFlag}
+	}
+
+	for _, names := range [][]string{f.helpFlags(), helpAll, f.versionFlags(), showConfig} {
+		for _, name := range names {
+			if (short != "" && name == "-"+short) || (long != "" && name == "--"+long) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// check user flags of f and all sub-commands against built-in flags
+// configured after binding
+func (f *FlagSet) checkReserved() (err error) {
+	err = f.visit(func(flag *Flag) error {
+		if name := f.reservedName(flag.Short, flag.Long); name != "" {
+			return Errorf(f, flag, 0, "reserved flag name used: %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range f.cmds {
+		err = cmd.checkReserved()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// whether the flag is shown in help and completion
+func (f *FlagSet) isVisible(flag *Flag) bool {
+	if flag.Hidden {
+		return false
+	}
+
+	if flag.Experimental {
+		return f.helpAll || os.Getenv("XFLAG_EXPERIMENTAL") == "1"
+	}
+
+	return true
+}
+
+// format built-in flag names for PrintDefaults
+func builtinFlagColumn(names []string) string {
+	var short, long []string
+	for _, name := range names {
+		if strings.HasPrefix(name, "--") {
+			long = append(long, name)
+		} else {
+			short = append(short, name)
+		}
+	}
+
+	if len(short) == 0 {
+		short = append(short, "  ")
+	}
+
+	return strings.Join(append(short, long...), "  ")
+}
+
+// return Version or the build information of the main module
+func (f *FlagSet) versionString() string {
+	if fs := f.versionSet(); fs != nil && fs.Version != "" {
+		return fs.Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+
+	version := fmt.Sprintf("%s %s", info.Main.Path, info.Main.Version)
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			version = fmt.Sprintf("%s (%s)", version, setting.Value)
+		}
+	}
+
+	return fmt.Sprintf("%s %s", version, info.GoVersion)
+}
+
+func (f *FlagSet) PrintVersion() {
+	fmt.Fprintln(os.Stdout, f.versionString())
+}
@@ -0,0 +1,23 @@
+package xflag
+
+import (
+	"strings"
+)
+
+// report all required flags neither set nor defaulted
+func (f *FlagSet) checkRequired(st *parseState) error {
+	var missing []string
+
+	f.visit(func(flag *Flag) error {
+		if flag.Required && !flag.IsSet && flag.DefValue == "" {
+			missing = append(missing, flag.name())
+		}
+		return nil
+	})
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return st.fail(Errorf(f, nil, ERROR_REQUIRED_FLAG, "required flags are missing: %s", strings.Join(missing, ", ")))
+}
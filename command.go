@@ -0,0 +1,51 @@
+package xflag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// print visible sub-commands in declaration order, grouped by Group
+func (f *FlagSet) printCommands() {
+	const format = "  %-30s  %s\n"
+
+	var (
+		groups []string
+		cmds   = make(map[string][]*FlagSet)
+	)
+
+	for _, cmd := range f.cmds {
+		if cmd.Hidden {
+			continue
+		}
+
+		if _, has := cmds[cmd.Group]; !has {
+			groups = append(groups, cmd.Group)
+		}
+		cmds[cmd.Group] = append(cmds[cmd.Group], cmd)
+	}
+
+	for _, group := range groups {
+		heading := group
+		if heading == "" {
+			heading = "Commands"
+		}
+		fmt.Fprintf(os.Stderr, "\n%s:\n", heading)
+
+		for _, cmd := range cmds[group] {
+			names := append([]string{cmd.Name}, cmd.Aliases...)
+			fmt.Fprintf(os.Stderr, format, strings.Join(names, ", "), cmd.Description)
+		}
+	}
+}
+
+// return names of the visible sub-commands in declaration order
+func (f *FlagSet) commandNames() (names []string) {
+	for _, cmd := range f.cmds {
+		if !cmd.Hidden {
+			names = append(names, cmd.Name)
+		}
+	}
+	return names
+}
@@ -65,34 +65,35 @@ func genComplWords(f *FlagSet, arguments []string) (completions []string) {
 	cmdComplete := func() (compl []string) {
 		// comple parameter
 		if prev != "" {
-			if flag := f.Flag(prev); flag != nil {
+			if flag := f.lookup(prev); flag != nil {
 				if boolFlag, ok := flag.Value.(boolTypeFlag); !ok || !boolFlag.IsBool() {
 					if flag.Completor != nil {
-						compl = append(compl, f.Flag(prev).Completor(words)...)
+						compl = append(compl, flag.Completor(words)...)
 					}
 					return
 				}
 			}
 		}
 
-		if strings.HasPrefix(cur, "-") && len(f.Args()) < 2 {
+		if strings.HasPrefix(cur, "-") && len(f.args) < 2 {
 			// complete flag
-			f.Visit(func(flag *Flag) (err error) {
-				if flag.Short != "" {
-					compl = append(compl, fmt.Sprintf("-%s ", flag.Short))
+			flags := f.globalFlags()
+			f.visit(func(flag *Flag) (err error) {
+				if f.isVisible(flag) {
+					flags = append(flags, flag)
 				}
-
-				if flag.Long != "" {
-					compl = append(compl, fmt.Sprintf("--%s ", flag.Long))
-				}
-
 				return
 			})
+
+			for _, flag := range flags {
+				shorts, longs := flag.names()
+				for _, name := range append(shorts, longs...) {
+					compl = append(compl, fmt.Sprintf("%s ", name))
+				}
+			}
 		} else {
 			// complete argument
-			for cmd := range f.cmdSet {
-				compl = append(compl, cmd)
-			}
+			compl = append(compl, f.commandNames()...)
 
 			if f.Completor != nil {
 				compl = append(compl, f.Completor(words)...)
@@ -103,14 +104,21 @@ func genComplWords(f *FlagSet, arguments []string) (completions []string) {
 	}
 
 	// self completion
-	if f.SubCommandName() == "" {
+	if f.cmdName == "" {
 		completions = cmdComplete()
 		// sub command completion
 	} else {
-		if arguments[len(arguments)-1] == f.cmdName {
-			completions = []string{f.cmdName}
-		} else {
-			completions = genComplWords(f.cmdSet[f.cmdName], f.Args()[1:])
+		sub := f.cmdSet[f.cmdName]
+		args := f.args
+
+		switch {
+		case len(args) == 0 || f.cmdSet[args[0]] != sub:
+			// DefaultCommand, not given on the command line
+			completions = genComplWords(sub, nil)
+		case f.cmdSet[arguments[len(arguments)-1]] == sub:
+			completions = []string{arguments[len(arguments)-1]}
+		default:
+			completions = genComplWords(sub, args[1:])
 		}
 	}
 
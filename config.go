@@ -0,0 +1,399 @@
+package xflag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ConfigFormat int
+
+const (
+	ConfigAuto ConfigFormat = iota // by file extension or content
+	ConfigJSON
+	ConfigINI
+)
+
+// key = value in a config file
+type configEntry struct {
+	key    string
+	values []string
+	line   int
+}
+
+// values for a FlagSet, sub-command sections are nested
+type configSection struct {
+	name     string
+	entries  []*configEntry
+	sections []*configSection
+	line     int
+}
+
+func (s *configSection) entry(key string, line int) *configEntry {
+	for _, e := range s.entries {
+		if e.key == key {
+			return e
+		}
+	}
+
+	e := &configEntry{key: key, line: line}
+	s.entries = append(s.entries, e)
+	return e
+}
+
+func (s *configSection) section(name string, line int) *configSection {
+	for _, sub := range s.sections {
+		if sub.name == name {
+			return sub
+		}
+	}
+
+	sub := &configSection{name: name, line: line}
+	s.sections = append(s.sections, sub)
+	return sub
+}
+
+// bind a flag giving the path of a JSON or INI config file.
+// config values are keyed by long flag names, sections hold the values of
+// sub-commands. the file is added as a Source.
+func (f *FlagSet) BindConfig(short, long, defValue, help string) (err error) {
+	err = f.BindVar(&f.configPath, short, long, defValue, help)
+	if err != nil {
+		return err
+	}
+
+	f.configFlag = f.flags[len(f.flags)-1]
+	f.AddSource(&configSource{fs: f})
+	return nil
+}
+
+func detectConfigFormat(path string, data []byte) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ConfigJSON
+	case ".ini", ".conf", ".cfg":
+		return ConfigINI
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return ConfigJSON
+	}
+	return ConfigINI
+}
+
+func readConfig(path string, format ConfigFormat) (*configSection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == ConfigAuto {
+		format = detectConfigFormat(path, data)
+	}
+
+	if format == ConfigJSON {
+		return parseJSONConfig(data)
+	}
+	return parseINIConfig(data)
+}
+
+// parse INI, "[remote.add]" is the section of sub-command "remote add".
+// repeated keys give several values.
+func parseINIConfig(data []byte) (*configSection, error) {
+	var (
+		root    = &configSection{}
+		section = root
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+		line    int
+	)
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "#"):
+			continue
+
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			section = root
+			for _, name := range strings.Split(text[1:len(text)-1], ".") {
+				section = section.section(strings.TrimSpace(name), line)
+			}
+
+		default:
+			key, value := text, "true"
+			if i := strings.Index(text, "="); i != -1 {
+				key, value = strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+			}
+
+			if strings.HasPrefix(value, `"`) {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				value = unquoted
+			}
+
+			e := section.entry(key, line)
+			e.values = append(e.values, value)
+		}
+	}
+
+	return root, scanner.Err()
+}
+
+// blank out lines commented by "//", as written by WriteConfigTemplate.
+// offsets are kept for line numbers.
+func stripJSONComments(data []byte) []byte {
+	stripped := make([]byte, 0, len(data))
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			line = bytes.Map(func(r rune) rune {
+				if r == '\n' {
+					return r
+				}
+				return ' '
+			}, line)
+		}
+		stripped = append(stripped, line...)
+	}
+	return stripped
+}
+
+// parse JSON, objects are sections of sub-commands or values of map flags
+func parseJSONConfig(data []byte) (*configSection, error) {
+	data = stripJSONComments(data)
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("config is not a JSON object")
+	}
+
+	return jsonSection(d, data, &configSection{})
+}
+
+// line number at offset
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// read members of an object into s, the opening brace has been read
+func jsonSection(d *json.Decoder, data []byte, s *configSection) (*configSection, error) {
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key := tok.(string)
+		line := lineAt(data, d.InputOffset())
+
+		tok, err = d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tok == json.Delim('{'):
+			_, err = jsonSection(d, data, s.section(key, line))
+
+		case tok == json.Delim('['):
+			e := s.entry(key, line)
+			for d.More() {
+				tok, err = d.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				str, err := jsonScalar(key, tok)
+				if err != nil {
+					return nil, err
+				}
+				e.values = append(e.values, str)
+			}
+			_, err = d.Token()
+
+		case tok == nil:
+
+		default:
+			var str string
+			str, err = jsonScalar(key, tok)
+			if err == nil {
+				e := s.entry(key, line)
+				e.values = append(e.values, str)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// closing brace
+	_, err := d.Token()
+	return s, err
+}
+
+func jsonScalar(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value of %q: %v", key, v)
+}
+
+// Source of the config file bound by BindConfig
+type configSource struct {
+	fs   *FlagSet // FlagSet the config flag is defined on
+	path string
+	root *configSection
+}
+
+// read the config file and check its keys
+func (c *configSource) Load(fs *FlagSet) (err error) {
+	c.path, c.root = fs.configPath, nil
+	if !fs.configFlag.IsSet {
+		c.path = fs.configFlag.DefValue
+	}
+
+	if c.path == "" {
+		return nil
+	}
+
+	c.root, err = readConfig(c.path, fs.ConfigFormat)
+	if err != nil {
+		return Errorf(fs, fs.configFlag, ERROR_CONFIG, "%s: %v", c.path, err)
+	}
+
+	if fs.AllowUnknownConfigKeys {
+		return nil
+	}
+
+	return c.check(fs, c.root)
+}
+
+// report keys matching no flag or sub-command
+func (c *configSource) check(fs *FlagSet, s *configSection) (err error) {
+	var errs Errors
+
+	unknown := func(key string, line int) {
+		errs = append(errs, Errorf(fs, nil, ERROR_CONFIG, "%s:%d: unknown key: %s", c.path, line, key))
+	}
+
+	for _, e := range s.entries {
+		if fs.configLookup(e.key) == nil {
+			unknown(e.key, e.line)
+		}
+	}
+
+	for _, sub := range s.sections {
+		if cmd, ok := fs.cmdSet[sub.name]; ok {
+			if err := c.check(cmd, sub); err != nil {
+				errs = append(errs, err.(Errors)...)
+			}
+		} else if flag := fs.configLookup(sub.name); flag == nil || !isMultiValue(flag) || len(sub.sections) > 0 {
+			// only map flags take objects
+			unknown(sub.name, sub.line)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// look up the values of flag in the section of fs, or of a selected
+// sub-command below for persistent flags
+func (c *configSource) Lookup(fs *FlagSet, flag *Flag) (values []string, origin string, ok bool) {
+	if c.root == nil || flag == c.fs.configFlag {
+		return nil, "", false
+	}
+
+	// path of commands from the config flag to fs
+	var path []*FlagSet
+	for p := fs; p != c.fs; p = p.parent {
+		if p == nil {
+			return nil, "", false
+		}
+		path = append([]*FlagSet{p}, path...)
+	}
+
+	s := c.root
+	for _, cmd := range path {
+		if s = s.findSection(cmd); s == nil {
+			return nil, "", false
+		}
+	}
+
+	for cmd := fs; s != nil; {
+		if v, line, found := s.values(cmd, flag); found {
+			values, origin, ok = v, fmt.Sprintf("%s:%d", c.path, line), true
+		}
+
+		if cmd.cmdName == "" {
+			break
+		}
+		cmd = cmd.cmdSet[cmd.cmdName]
+		s = s.findSection(cmd)
+	}
+
+	return values, origin, ok
+}
+
+// return the section of a sub-command by name or alias
+func (s *configSection) findSection(cmd *FlagSet) *configSection {
+	for _, sub := range s.sections {
+		if sub.name == cmd.Name || matchFlagName(cmd.Aliases, sub.name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// return the values of flag in the section read for fs
+func (s *configSection) values(fs *FlagSet, flag *Flag) (values []string, line int, ok bool) {
+	for _, e := range s.entries {
+		if fs.configLookup(e.key) == flag {
+			return e.values, e.line, true
+		}
+	}
+
+	// {"label": {"key": "value"}} of map flags
+	for _, sub := range s.sections {
+		if fs.configLookup(sub.name) == flag {
+			for _, e := range sub.entries {
+				for _, value := range e.values {
+					values = append(values, e.key+"="+value)
+				}
+			}
+			return values, sub.line, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// look up a flag by config key, including inherited persistent flags
+func (f *FlagSet) configLookup(key string) *Flag {
+	if len(key) == 1 {
+		return f.lookup("-" + key)
+	}
+	return f.lookup("--" + key)
+}
@@ -0,0 +1,131 @@
+package xflag
+
+import (
+	"fmt"
+	"strings"
+)
+
+type constraintKind int
+
+const (
+	constraintExclusive constraintKind = iota
+	constraintTogether
+	constraintOneOf
+	constraintRequiredIf
+)
+
+// relationship between flags checked after parsing
+type constraint struct {
+	kind  constraintKind
+	cond  *Flag // for constraintRequiredIf
+	flags []*Flag
+}
+
+func joinFlagNames(flags []*Flag) string {
+	var names []string
+	for _, flag := range flags {
+		names = append(names, flag.name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// description shown in help
+func (c *constraint) String() string {
+	switch c.kind {
+	case constraintExclusive:
+		return fmt.Sprintf("%s are mutually exclusive", joinFlagNames(c.flags))
+	case constraintTogether:
+		return fmt.Sprintf("%s must be used together", joinFlagNames(c.flags))
+	case constraintOneOf:
+		return fmt.Sprintf("at least one of %s is required", joinFlagNames(c.flags))
+	default:
+		return fmt.Sprintf("%s requires %s", c.cond.name(), joinFlagNames(c.flags))
+	}
+}
+
+// return the violation message, "" if satisfied.
+// exclusive groups count only flags which were set, so defaults never
+// conflict. the other kinds are triggered by flags which were set and
+// satisfied by flags which were set or have DefValue, as for Required.
+func (c *constraint) check() string {
+	var set, missing []*Flag
+	for _, flag := range c.flags {
+		if flag.IsSet {
+			set = append(set, flag)
+		}
+		if !flag.IsSet && flag.DefValue == "" {
+			missing = append(missing, flag)
+		}
+	}
+
+	switch {
+	case c.kind == constraintExclusive && len(set) > 1:
+		return fmt.Sprintf("%s cannot be used together", joinFlagNames(set))
+	case c.kind == constraintTogether && len(set) > 0 && len(missing) > 0:
+		return fmt.Sprintf("%s must be used together, missing %s", joinFlagNames(c.flags), joinFlagNames(missing))
+	case c.kind == constraintOneOf && len(missing) == len(c.flags):
+		return fmt.Sprintf("at least one of %s is required", joinFlagNames(c.flags))
+	case c.kind == constraintRequiredIf && c.cond.IsSet && len(missing) > 0:
+		return fmt.Sprintf("%s requires %s", c.cond.name(), joinFlagNames(missing))
+	}
+
+	return ""
+}
+
+func (f *FlagSet) addConstraint(kind constraintKind, cond string, names []string) (err error) {
+	c := &constraint{kind: kind}
+
+	if kind == constraintRequiredIf {
+		if c.cond = f.Flag(cond); c.cond == nil {
+			return Errorf(f, nil, ERROR_UNDEFINED_FLAG, "%s flag is undefined", cond)
+		}
+	}
+
+	for _, name := range names {
+		flag := f.Flag(name)
+		if flag == nil {
+			return Errorf(f, nil, ERROR_UNDEFINED_FLAG, "%s flag is undefined", name)
+		}
+		c.flags = append(c.flags, flag)
+	}
+
+	if len(c.flags) == 0 {
+		return Errorf(f, nil, 0, "no flags given for constraint")
+	}
+
+	f.constraints = append(f.constraints, c)
+	return nil
+}
+
+// at most one of the named flags can be set
+func (f *FlagSet) MutuallyExclusive(names ...string) error {
+	return f.addConstraint(constraintExclusive, "", names)
+}
+
+// either all or none of the named flags are set
+func (f *FlagSet) RequiredTogether(names ...string) error {
+	return f.addConstraint(constraintTogether, "", names)
+}
+
+// at least one of the named flags is set
+func (f *FlagSet) RequireOneOf(names ...string) error {
+	return f.addConstraint(constraintOneOf, "", names)
+}
+
+// the required flags are set whenever the named flag is set
+func (f *FlagSet) RequiredIf(name string, required ...string) error {
+	return f.addConstraint(constraintRequiredIf, name, required)
+}
+
+// report all violated constraints
+func (f *FlagSet) checkConstraints(st *parseState) (err error) {
+	for _, c := range f.constraints {
+		if msg := c.check(); msg != "" {
+			err = st.fail(Errorf(f, nil, ERROR_CONSTRAINT, "%s", msg))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package xflag
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// convert a name to the environment variable style, "max-conns" to "MAX_CONNS"
+func envStyle(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// prefix of automatic environment variable names
+func (f *FlagSet) envPrefix() string {
+	switch {
+	case f.EnvPrefix != "":
+		return f.EnvPrefix
+	case f.parent != nil:
+		return f.parent.envPrefix() + "_" + envStyle(f.Name)
+	default:
+		return envStyle(f.Name)
+	}
+}
+
+// whether AutoEnv is enabled on f or an ancestor
+func (f *FlagSet) autoEnv() bool {
+	for fs := f; fs != nil; fs = fs.parent {
+		if fs.AutoEnv {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FlagSet) envSeparator() string {
+	if f.EnvSeparator == "" {
+		return ","
+	}
+	return f.EnvSeparator
+}
+
+// return the environment variable of the flag, "" if none
+func (flag *Flag) envName() string {
+	switch {
+	case flag.EnvVar != "":
+		return flag.EnvVar
+	case flag.fs != nil && flag.fs.autoEnv() && flag.Long != "":
+		return flag.fs.envPrefix() + "_" + envStyle(flag.Long)
+	default:
+		return ""
+	}
+}
+
+// whether the flag takes several values, like slices and maps
+func isMultiValue(flag *Flag) bool {
+	switch reflect.ValueOf(flag.Value.Get()).Kind() {
+	case reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// Source of environment variables, consulted before the added sources
+type envSource struct{}
+
+func (envSource) Load(fs *FlagSet) error { return nil }
+
+func (envSource) Lookup(fs *FlagSet, flag *Flag) (values []string, origin string, ok bool) {
+	name := flag.envName()
+	if name == "" {
+		return nil, "", false
+	}
+
+	env, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, "", false
+	}
+
+	values = []string{env}
+	if isMultiValue(flag) {
+		values = strings.Split(env, fs.envSeparator())
+	}
+
+	return values, "env " + name, true
+}
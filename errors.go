@@ -1,7 +1,9 @@
 package xflag
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 type ErrorCode int
@@ -11,13 +13,27 @@ const (
 	ERROR_HELP_REQUESTED
 	ERROR_UNDEFINED_FLAG
 	ERROR_EMPTY_VALUE
+	ERROR_INVALID_VALUE
+	ERROR_VERSION_REQUESTED
+	ERROR_REQUIRED_FLAG
+	ERROR_CONSTRAINT
+	ERROR_VALIDATION
+	ERROR_COMMAND_REQUIRED
+	ERROR_CONFIG
+	ERROR_SHOW_CONFIG_REQUESTED
 )
 
+// returned by Flag.Action to stop parsing, Parse returns it unchanged
+var ErrStopParsing = errors.New("stop parsing")
+
 type Error struct {
 	error
 	Code    ErrorCode
 	FlagSet *FlagSet
 	Flag    *Flag
+	Value   string // offending value of ERROR_INVALID_VALUE
+	// similar flags or commands for an undefined one
+	Suggestions []string
 }
 
 func Errorf(fs *FlagSet, flag *Flag, code ErrorCode, format string, args ...interface{}) *Error {
@@ -36,13 +52,37 @@ func (e *Error) Error() string {
 	case e.FlagSet != nil:
 		return fmt.Sprintf("%s: %s", e.FlagSet, e.error.Error())
 	default:
-		return e.Error()
+		return e.error.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.error)
+}
+
+// Errors is returned by Parse when FlagSet.CollectErrors is enabled
+type Errors []*Error
+
+func (e Errors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	var errs []error
+	for _, err := range e {
+		errs = append(errs, err)
 	}
+	return errs
 }
 
 func getError(err error) *Error {
-	if err, ok := err.(*Error); ok {
-		return err
+	var e *Error
+	if errors.As(err, &e) {
+		return e
 	}
 	return nil
 }
@@ -54,8 +94,16 @@ func GetErrorCode(err error) ErrorCode {
 	return -1
 }
 
+// print help, or the version or values when requested by the built-in flags
 func PrintHelp(err error) {
 	if err := getError(err); err != nil {
-		err.FlagSet.PrintHelp()
+		switch err.Code {
+		case ERROR_VERSION_REQUESTED:
+			err.FlagSet.PrintVersion()
+		case ERROR_SHOW_CONFIG_REQUESTED:
+			err.FlagSet.PrintValues()
+		default:
+			err.FlagSet.PrintHelp()
+		}
 	}
 }
@@ -0,0 +1,99 @@
+package xflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// implemented by errors carrying their own exit code, such as *exec.ExitError
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// map an error returned by Parse or Execute to a process exit code
+func ExitCode(err error) int {
+	var coder ExitCoder
+
+	switch {
+	case err == nil, errors.Is(err, ErrStopParsing):
+		return 0
+	case errors.As(err, &coder):
+		return coder.ExitCode()
+	case errors.Is(err, context.Canceled):
+		return 130
+	case errors.Is(err, context.DeadlineExceeded):
+		return 124
+	}
+
+	if e := getError(err); e != nil {
+		switch e.Code {
+		case ERROR_HELP_REQUESTED, ERROR_VERSION_REQUESTED, ERROR_SHOW_CONFIG_REQUESTED:
+			return 0
+		case ERROR_VALIDATION:
+			return 1
+		default:
+			// usage error
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// parse arguments and call Run of the selected sub-command.
+// when the selected command has no Run, the nearest ancestor with one is
+// called. Run receives the FlagSet it is defined on and its Args().
+func (f *FlagSet) Execute(ctx context.Context, arguments []string) (err error) {
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	err = f.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	cmd := f
+	for cmd.cmdName != "" {
+		cmd = cmd.cmdSet[cmd.cmdName]
+	}
+
+	for handler := cmd; handler != nil; handler = handler.parent {
+		if handler.Run != nil {
+			err = ctx.Err()
+			if err != nil {
+				return err
+			}
+			return handler.Run(ctx, handler, handler.Args())
+		}
+
+		if handler == f {
+			break
+		}
+	}
+
+	return Errorf(cmd, nil, 0, "no handler for command")
+}
+
+// execute os.Args with a context cancelled by SIGINT or SIGTERM,
+// report the error and exit with ExitCode
+func (f *FlagSet) Main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := f.Execute(ctx, os.Args[1:])
+	stop()
+
+	switch code := GetErrorCode(err); {
+	case err == nil, errors.Is(err, ErrStopParsing):
+	case code == ERROR_HELP_REQUESTED || code == ERROR_VERSION_REQUESTED || code == ERROR_SHOW_CONFIG_REQUESTED:
+		PrintHelp(err)
+	default:
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+
+	os.Exit(ExitCode(err))
+}
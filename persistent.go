@@ -0,0 +1,75 @@
+package xflag
+
+import (
+	"strings"
+)
+
+// look up a flag by name with dashes, including inherited persistent flags
+func (f *FlagSet) lookup(name string) (flag *Flag) {
+	switch {
+	case strings.HasPrefix(name, "--"):
+		flag, _, _ = f.lookupLong(name[2:])
+	case strings.HasPrefix(name, "-"):
+		flag, _, _ = f.lookupShort(name[1:])
+	}
+	return flag
+}
+
+// look up a long flag of f or a persistent flag of its ancestors
+func (f *FlagSet) lookupLong(name string) (flag *Flag, owner *FlagSet, ok bool) {
+	if flag, ok = f.longFlags[f.longKey(name)]; ok {
+		return flag, f, true
+	}
+
+	for p := f.parent; p != nil; p = p.parent {
+		if flag, ok = p.longFlags[p.longKey(name)]; ok && flag.Persistent {
+			return flag, p, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// look up a short flag of f or a persistent flag of its ancestors
+func (f *FlagSet) lookupShort(name string) (flag *Flag, owner *FlagSet, ok bool) {
+	if flag, ok = f.shortFlags[name]; ok {
+		return flag, f, true
+	}
+
+	for p := f.parent; p != nil; p = p.parent {
+		if flag, ok = p.shortFlags[name]; ok && flag.Persistent {
+			return flag, p, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// return visible persistent flags inherited from the ancestors,
+// flags shadowed by a nearer definition are left out
+func (f *FlagSet) globalFlags() (flags []*Flag) {
+	for p := f.parent; p != nil; p = p.parent {
+		p.visit(func(flag *Flag) error {
+			if !flag.Persistent || !p.isVisible(flag) {
+				return nil
+			}
+
+			if flag.Long != "" {
+				if other, _, _ := f.lookupLong(flag.Long); other != flag {
+					return nil
+				}
+			}
+
+			if flag.Short != "" {
+				if other, _, _ := f.lookupShort(flag.Short); other != flag {
+					return nil
+				}
+			}
+
+			flags = append(flags, flag)
+			return nil
+		})
+	}
+
+	return flags
+}
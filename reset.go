@@ -0,0 +1,191 @@
+package xflag
+
+import (
+	"reflect"
+)
+
+// variable bound by BindVar and its value at binding
+type binding struct {
+	v    reflect.Value // addressable variable
+	orig reflect.Value // never modified
+}
+
+func newBinding(v reflect.Value) *binding {
+	return &binding{v: v, orig: copyValue(bindingTarget(v))}
+}
+
+// what holds the value, pointers such as *MyValue keep it in what they
+// point to
+func bindingTarget(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem()
+	}
+	return v
+}
+
+// copy v, slices and maps are not shared with the copy
+func copyValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+
+	switch {
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		reflect.Copy(c, v)
+	case v.Kind() == reflect.Map && !v.IsNil():
+		c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+	default:
+		c.Set(v)
+	}
+
+	return c
+}
+
+// set the variable back to its value at binding
+func (b *binding) restore() {
+	bindingTarget(b.v).Set(copyValue(b.orig))
+}
+
+// bind the original value to v, a new variable of the same type
+func (b *binding) cloneTo(v reflect.Value) *binding {
+	if b.v.Kind() == reflect.Ptr && !b.v.IsNil() {
+		v.Set(reflect.New(b.v.Type().Elem()))
+	}
+
+	c := &binding{v: v, orig: b.orig}
+	c.restore()
+	return c
+}
+
+// restore the bound variables of f and all sub-commands to their values at
+// binding and clear IsSet, Origin, the selected sub-command, the arguments
+// and Result(), so f can parse again
+func (f *FlagSet) Reset() {
+	mu := f.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
+	f.reset()
+}
+
+func (f *FlagSet) reset() {
+	for _, flag := range f.flags {
+		flag.IsSet = false
+		flag.Origin = ""
+		if flag.bound != nil {
+			flag.bound.restore()
+		}
+	}
+
+	f.cmdName = ""
+	f.args = nil
+	f.unknown = nil
+	f.result = nil
+	f.helpAll = false
+
+	for _, cmd := range f.cmds {
+		cmd.reset()
+	}
+}
+
+// return an independent copy of f and its sub-commands, as f would be
+// after Reset. the copy has its own storage for every flag, read through
+// Flag(name).Value, while Actions, Validators and Sources are shared.
+// Validate hooks of BindStruct check the original struct and are not
+// copied.
+func (f *FlagSet) Clone() *FlagSet {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return f.clone()
+}
+
+func (f *FlagSet) clone() *FlagSet {
+	c := &FlagSet{
+		Name:                   f.Name,
+		Usage:                  f.Usage,
+		Description:            f.Description,
+		Aliases:                f.Aliases,
+		Hidden:                 f.Hidden,
+		Group:                  f.Group,
+		RequireCommand:         f.RequireCommand,
+		DefaultCommand:         f.DefaultCommand,
+		Run:                    f.Run,
+		EnableCompletion:       f.EnableCompletion,
+		Completor:              f.Completor,
+		CollectErrors:          f.CollectErrors,
+		PassUnknown:            f.PassUnknown,
+		AutoEnv:                f.AutoEnv,
+		EnvPrefix:              f.EnvPrefix,
+		EnvSeparator:           f.EnvSeparator,
+		EnableShowConfig:       f.EnableShowConfig,
+		ConfigFormat:           f.ConfigFormat,
+		AllowUnknownConfigKeys: f.AllowUnknownConfigKeys,
+		Normalize:              f.Normalize,
+		HelpFlags:              f.HelpFlags,
+		DisableHelp:            f.DisableHelp,
+		EnableVersion:          f.EnableVersion,
+		Version:                f.Version,
+		VersionFlags:           f.VersionFlags,
+		shortFlags:             make(map[string]*Flag),
+		longFlags:              make(map[string]*Flag),
+	}
+
+	flags := make(map[*Flag]*Flag)
+	for _, flag := range f.flags {
+		nf := &Flag{}
+		*nf = *flag
+		nf.fs = c
+		nf.IsSet = false
+		nf.Origin = ""
+		nf.Aliases = append([]string(nil), flag.Aliases...)
+		nf.DeprecatedAliases = append([]string(nil), flag.DeprecatedAliases...)
+		nf.Validators = append([]Validator(nil), flag.Validators...)
+
+		if flag.bound != nil {
+			v := reflect.New(flag.bound.v.Type()).Elem()
+			if flag == f.configFlag {
+				v = reflect.ValueOf(&c.configPath).Elem()
+			}
+
+			nf.bound = flag.bound.cloneTo(v)
+			nf.Value = newValue(v)
+		}
+
+		flags[flag] = nf
+		c.flags = append(c.flags, nf)
+	}
+
+	for name, flag := range f.shortFlags {
+		c.shortFlags[name] = flags[flag]
+	}
+	for name, flag := range f.longFlags {
+		c.longFlags[name] = flags[flag]
+	}
+	c.configFlag = flags[f.configFlag]
+
+	for _, con := range f.constraints {
+		nc := &constraint{kind: con.kind, cond: flags[con.cond]}
+		for _, flag := range con.flags {
+			nc.flags = append(nc.flags, flags[flag])
+		}
+		c.constraints = append(c.constraints, nc)
+	}
+
+	for _, source := range f.sources {
+		if _, ok := source.(*configSource); ok {
+			source = &configSource{fs: c}
+		}
+		c.sources = append(c.sources, source)
+	}
+
+	for _, cmd := range f.cmds {
+		c.AddSubCommand(cmd.clone())
+	}
+
+	return c
+}
@@ -0,0 +1,49 @@
+package xflag
+
+import (
+	"strings"
+)
+
+// Occurrence is a single appearance of a flag on the command line.
+type Occurrence struct {
+	Flag  *Flag
+	Token string // raw argument the flag was read from, e.g. "-vvv" or "--name=value"
+	Index int    // position of Token in the arguments passed to Parse
+	Value string // value handed to Flag.Value.Set
+}
+
+// ParseResult records how Parse interpreted its arguments.
+// A new ParseResult is built by every Parse call and is not modified afterwards.
+type ParseResult struct {
+	Occurrences []Occurrence
+	Commands    []string // selected sub-command path
+	Args        []string // remained positional arguments
+}
+
+// return occurrences of the named flag in command line order
+func (r *ParseResult) Lookup(name string) (occurrences []Occurrence) {
+	if !strings.HasPrefix(name, "-") {
+		short, long := splitAlias(name)
+		name = "-" + short
+		if long != "" {
+			name = "--" + long
+		}
+	}
+
+	for _, o := range r.Occurrences {
+		if o.Flag.hasName(name) {
+			occurrences = append(occurrences, o)
+		}
+	}
+
+	return occurrences
+}
+
+// return the record of the most recent Parse, nil before the first one
+func (f *FlagSet) Result() *ParseResult {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return f.result
+}
@@ -0,0 +1,142 @@
+package xflag
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// return the values of a flag as strings accepted by Value.Set, none when
+// Get returns nil
+func flagValues(flag *Flag) (values []string) {
+	v := reflect.ValueOf(flag.Value.Get())
+
+	switch v.Kind() {
+	case reflect.Invalid:
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, formatValue(v.Index(i).Interface()))
+		}
+	case reflect.Map:
+		var keys []string
+		for _, key := range v.MapKeys() {
+			keys = append(keys, formatValue(key.Interface()))
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			values = append(values, key+"="+formatValue(v.MapIndex(reflect.ValueOf(key)).Interface()))
+		}
+	default:
+		values = append(values, formatValue(v.Interface()))
+	}
+
+	return values
+}
+
+// return the command line arguments of a flag
+func flagArgs(flag *Flag) (args []string) {
+	boolFlag, ok := flag.Value.(boolTypeFlag)
+	isBool := ok && boolFlag.IsBool()
+
+	for _, value := range flagValues(flag) {
+		switch {
+		case flag.Long != "" && isBool && value == "true":
+			args = append(args, "--"+flag.Long)
+		case flag.Long != "":
+			args = append(args, "--"+flag.Long+"="+value)
+		case isBool && value == "true":
+			args = append(args, "-"+flag.Short)
+		case isBool:
+			// a short boolean flag cannot be set to false
+		case value == "":
+			// "-s" alone would take the next argument
+			args = append(args, "-"+flag.Short, "")
+		default:
+			args = append(args, "-"+flag.Short+value)
+		}
+	}
+
+	return args
+}
+
+// return canonical arguments reproducing the parsed values: flags which
+// were set, in definition order, the sub-command path and the positional
+// arguments. values are joined to flag names, so they are never taken for
+// flags. false values of short-only boolean flags, including elements of
+// []bool, cannot be written and are left out.
+func (f *FlagSet) ToArgs() (args []string) {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	fs := f
+	for {
+		fs.visit(func(flag *Flag) error {
+			if flag.IsSet {
+				args = append(args, flagArgs(flag)...)
+			}
+			return nil
+		})
+		args = append(args, fs.unknown...)
+
+		if fs.cmdName == "" {
+			break
+		}
+
+		args = append(args, fs.cmdName)
+		fs = fs.cmdSet[fs.cmdName]
+	}
+
+	positional := fs.args
+	for _, arg := range positional {
+		if strings.HasPrefix(arg, "-") {
+			args = append(args, "--")
+			break
+		}
+	}
+
+	return append(args, positional...)
+}
+
+// return NAME=value environment assignments of the flags which were set in
+// f and the selected sub-commands, read back by AutoEnv with EnvPrefix set
+// to prefix. flags with EnvVar keep their variable, slices and maps are
+// joined by the separator. short-only flags without EnvVar have no
+// variable and are left out.
+func (f *FlagSet) ToEnv(prefix string) (env []string) {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if prefix == "" {
+		prefix = f.envPrefix()
+	}
+
+	for fs := f; fs != nil; fs = fs.cmdSet[fs.cmdName] {
+		fs.visit(func(flag *Flag) error {
+			name := flag.EnvVar
+			if name == "" && flag.Long != "" {
+				name = prefix + "_" + envStyle(flag.Long)
+			}
+
+			if flag.IsSet && name != "" {
+				env = append(env, name+"="+strings.Join(flagValues(flag), fs.envSeparator()))
+			}
+			return nil
+		})
+
+		if fs.cmdName == "" {
+			break
+		}
+
+		sub := fs.cmdSet[fs.cmdName]
+		if sub.EnvPrefix != "" {
+			prefix = sub.EnvPrefix
+		} else {
+			prefix = prefix + "_" + envStyle(sub.Name)
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,89 @@
+package xflag
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// lock of the command tree, held by the top level FlagSet
+func (f *FlagSet) mutex() *sync.RWMutex {
+	for f.parent != nil {
+		f = f.parent
+	}
+	return &f.mu
+}
+
+// return copies of the current values of f, keyed by long flag name or
+// short name for flags without one. the values are read at once, never
+// in the middle of Parse or Set.
+func (f *FlagSet) Snapshot() map[string]interface{} {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	values := make(map[string]interface{})
+	f.visit(func(flag *Flag) error {
+		v := reflect.ValueOf(flag.Value.Get())
+		if v.IsValid() {
+			values[flag.configKey()] = copyValue(v).Interface()
+		} else {
+			values[flag.configKey()] = nil
+		}
+		return nil
+	})
+
+	return values
+}
+
+// set a flag of f, or a persistent flag of its ancestors, as if it was
+// given once on the command line. the value is checked by the Validators
+// of the flag and left unchanged when invalid. Origin is OriginSet.
+func (f *FlagSet) Set(name, value string) (err error) {
+	mu := f.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !strings.HasPrefix(name, "-") {
+		if len(name) == 1 {
+			name = "-" + name
+		} else {
+			name = "--" + name
+		}
+	}
+
+	flag := f.lookup(name)
+	if flag == nil {
+		return f.undefinedFlagError(name)
+	}
+
+	// restored when the value is invalid
+	var orig reflect.Value
+	if flag.bound != nil {
+		orig = copyValue(bindingTarget(flag.bound.v))
+	}
+
+	flag.clearFieldDefault()
+	err = flag.Value.Set(value)
+	if err == nil {
+		for _, validator := range flag.Validators {
+			if err = validator(flag.Value.Get()); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		if orig.IsValid() {
+			bindingTarget(flag.bound.v).Set(orig)
+		}
+
+		e := Errorf(f, flag, ERROR_INVALID_VALUE, "invalid value %q: %w", value, err)
+		e.Value = value
+		return e
+	}
+
+	flag.IsSet = true
+	flag.Origin = OriginSet
+	return nil
+}
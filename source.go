@@ -0,0 +1,151 @@
+package xflag
+
+import (
+	"fmt"
+	"os"
+)
+
+// Source provides values of flags not given on the command line.
+//
+// values are taken, in order of priority, from the command line,
+// environment variables, the sources added to the FlagSets from the top
+// level to the selected sub-command in the order added, and DefValue.
+type Source interface {
+	// prepare for the lookups of a Parse, fs is the FlagSet the source
+	// was added to
+	Load(fs *FlagSet) error
+	// return values of flag defined on fs, and where they came from such
+	// as "config.json:12"
+	Lookup(fs *FlagSet, flag *Flag) (values []string, origin string, ok bool)
+}
+
+// origins of values not from a Source
+const (
+	OriginCommandLine = "command line"
+	OriginDefault     = "default"
+	OriginSet         = "set" // by FlagSet.Set
+)
+
+func (f *FlagSet) AddSource(source Source) {
+	f.sources = append(f.sources, source)
+}
+
+// load sources of f, errors are reported for each key
+func (f *FlagSet) loadSources(st *parseState) (err error) {
+	// the config path itself is read from the command line, the
+	// environment or its default before the config file
+	if flag := f.configFlag; flag != nil && !flag.IsSet {
+		if values, origin, ok := (envSource{}).Lookup(f, flag); ok {
+			if err = f.setSourceValues(flag, values, origin, st); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, source := range f.sources {
+		err = source.Load(f)
+		if errs, ok := err.(Errors); ok {
+			for _, e := range errs {
+				if err = st.fail(e); err != nil {
+					return err
+				}
+			}
+		} else if e := getError(err); e != nil {
+			err = st.fail(e)
+		} else if err != nil {
+			err = st.fail(Errorf(f, nil, ERROR_CONFIG, "%w", err))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set flags not given on the command line from the environment and the
+// sources of chain, the FlagSets from the top level to f
+func (f *FlagSet) applySources(chain []*FlagSet, st *parseState) error {
+	return f.visit(func(flag *Flag) error {
+		if flag.IsSet {
+			return nil
+		}
+
+		values, origin, ok := envSource{}.Lookup(f, flag)
+		for i := 0; !ok && i < len(chain); i++ {
+			for _, source := range chain[i].sources {
+				if values, origin, ok = source.Lookup(f, flag); ok {
+					break
+				}
+			}
+		}
+
+		if !ok {
+			return nil
+		}
+
+		return f.setSourceValues(flag, values, origin, st)
+	})
+}
+
+// set values of a flag read from a source
+func (f *FlagSet) setSourceValues(flag *Flag, values []string, origin string, st *parseState) error {
+	flag.clearFieldDefault()
+	flag.IsSet = true
+	flag.Origin = origin
+	for _, value := range values {
+		if err := flag.Value.Set(value); err != nil {
+			e := Errorf(f, flag, ERROR_INVALID_VALUE, "invalid value %q from %s: %w", value, origin, err)
+			e.Value = value
+			return st.fail(e)
+		}
+	}
+	return nil
+}
+
+// print the effective values of f and the selected sub-commands with
+// their origins
+func (f *FlagSet) PrintValues() {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	const format = "  %-30s  %s\n"
+
+	for fs := f; fs != nil; fs = fs.cmdSet[fs.cmdName] {
+		if fs != f {
+			fmt.Fprintf(os.Stdout, "\n")
+		}
+		fmt.Fprintf(os.Stdout, "%s:\n", fs.Name)
+
+		fs.visit(func(flag *Flag) error {
+			if flag.Hidden {
+				return nil
+			}
+
+			value := formatValue(flag.Value.Get())
+			if flag.Secret && value != "" {
+				value = "******"
+			}
+			origin := flag.Origin
+			if origin == "" {
+				origin = "unset"
+			}
+
+			fmt.Fprintf(os.Stdout, format, flag.name()+" = "+value, "("+origin+")")
+			return nil
+		})
+
+		if fs.cmdName == "" {
+			break
+		}
+	}
+}
+
+// name of the built-in flag printing the effective values
+const showConfigFlag = "--show-config"
+
+// whether the argument requests PrintValues
+func (f *FlagSet) isShowConfig(arg string) bool {
+	return f.EnableShowConfig && arg == showConfigFlag
+}
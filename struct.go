@@ -2,6 +2,7 @@ package xflag
 
 import (
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +19,17 @@ func (f *FlagSet) BindStruct(opt interface{}) (err error) {
 
 	t := optValue.Type()
 
+	// flag groups of constraint tags, in order of appearance
+	type group struct {
+		kind  constraintKind
+		names []string
+	}
+	var (
+		groups     []*group
+		groupIndex = make(map[string]*group)
+		requires   [][]string
+	)
+
 	for i := 0; i < t.NumField(); i++ {
 		var (
 			field      = t.Field(i)
@@ -28,6 +40,29 @@ func (f *FlagSet) BindStruct(opt interface{}) (err error) {
 			defValue   = ""
 		)
 
+		// unexported field
+		if field.PkgPath != "" {
+			continue
+		}
+
+		// nested option struct, tagged fields and structs such as time.Time
+		// are bound as flags
+		if _, tagged := field.Tag.Lookup("xflag"); !tagged && isOptionStruct(field.Type) {
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				fieldValue.Set(reflect.New(field.Type.Elem()))
+			}
+
+			if fieldValue.Kind() != reflect.Ptr {
+				fieldValue = fieldValue.Addr()
+			}
+
+			err = f.BindStruct(fieldValue.Interface())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		// struct tag parsing
 		if v, ok := field.Tag.Lookup("xflag"); ok {
 			terms := strings.SplitN(v, ",", 4)
@@ -61,7 +96,198 @@ func (f *FlagSet) BindStruct(opt interface{}) (err error) {
 		if err != nil {
 			return err
 		}
+
+		name := f.flags[len(f.flags)-1].name()
+		flag := f.Flag(name)
+
+		// the field value is the default when the tag has none
+		if defValue == "" && !fieldValue.Elem().IsZero() {
+			flag.DefValue = strings.Join(flagValues(flag), ",")
+			flag.defFromField = flag.DefValue != ""
+		}
+
+		// alias:"dryrun,n"
+		if v, ok := field.Tag.Lookup("alias"); ok {
+			err = f.AddAlias(name, splitTagList(v)...)
+			if err != nil {
+				return err
+			}
+		}
+
+		// deprecated:"dry_run"
+		if v, ok := field.Tag.Lookup("deprecated"); ok {
+			err = f.AddDeprecatedAlias(name, splitTagList(v)...)
+			if err != nil {
+				return err
+			}
+		}
+
+		// hidden:"true"
+		flag.Hidden, err = tagBool(f, field, "hidden")
+		if err != nil {
+			return err
+		}
+
+		// experimental:"true"
+		flag.Experimental, err = tagBool(f, field, "experimental")
+		if err != nil {
+			return err
+		}
+
+		// secret:"true"
+		flag.Secret, err = tagBool(f, field, "secret")
+		if err != nil {
+			return err
+		}
+
+		// required:"true"
+		flag.Required, err = tagBool(f, field, "required")
+		if err != nil {
+			return err
+		}
+
+		// persistent:"true"
+		flag.Persistent, err = tagBool(f, field, "persistent")
+		if err != nil {
+			return err
+		}
+
+		// env:"APP_PORT"
+		if v, ok := field.Tag.Lookup("env"); ok {
+			flag.EnvVar = strings.TrimSpace(v)
+		}
+
+		// min, max, len, pattern, oneof and nonempty
+		validators, err := tagValidators(f, field, fieldValue.Type().Elem())
+		if err != nil {
+			return err
+		}
+		flag.Validators = append(flag.Validators, validators...)
+
+		// exclusive:"format", together:"tls", atleastone:"source"
+		for _, kind := range []constraintKind{constraintExclusive, constraintTogether, constraintOneOf} {
+			key := constraintTags[kind]
+			v, ok := field.Tag.Lookup(key)
+			if !ok {
+				continue
+			}
+
+			for _, groupName := range splitTagList(v) {
+				g, has := groupIndex[key+"/"+groupName]
+				if !has {
+					g = &group{kind: kind}
+					groupIndex[key+"/"+groupName] = g
+					groups = append(groups, g)
+				}
+				g.names = append(g.names, name)
+			}
+		}
+
+		// requires:"ca,key"
+		if v, ok := field.Tag.Lookup("requires"); ok {
+			requires = append(requires, append([]string{name}, splitTagList(v)...))
+		}
+	}
+
+	for _, g := range groups {
+		err = f.addConstraint(g.kind, "", g.names)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range requires {
+		err = f.RequiredIf(r[0], r[1:]...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// nested structs were bound first, so they are validated first
+	if v, ok := opt.(validator); ok {
+		f.validators = append(f.validators, v)
 	}
 
 	return nil
 }
+
+// clear a slice or map holding the value of its struct field before the
+// first explicit value, which replaces it as it would replace a tag default
+func (flag *Flag) clearFieldDefault() {
+	if flag.IsSet || !flag.defFromField || flag.bound == nil || !isMultiValue(flag) {
+		return
+	}
+
+	target := bindingTarget(flag.bound.v)
+	target.Set(reflect.Zero(target.Type()))
+}
+
+// implemented by option structs checked after Parse
+type validator interface {
+	Validate() error
+}
+
+// whether t is a struct, or pointer to struct, with exported fields holding
+// flags rather than a Value
+func isOptionStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	valueType := reflect.TypeOf((*Value)(nil)).Elem()
+	if t.Kind() != reflect.Struct || t.Implements(valueType) || reflect.PtrTo(t).Implements(valueType) {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// call Validate of the bound option structs
+func (f *FlagSet) runValidators(st *parseState) (err error) {
+	for _, v := range f.validators {
+		if verr := v.Validate(); verr != nil {
+			err = st.fail(Errorf(f, nil, ERROR_VALIDATION, "%w", verr))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// struct tags of constraint groups
+var constraintTags = map[constraintKind]string{
+	constraintExclusive: "exclusive",
+	constraintTogether:  "together",
+	constraintOneOf:     "atleastone",
+}
+
+// parse a boolean tag value, false if the tag is absent
+func tagBool(f *FlagSet, field reflect.StructField, key string) (bool, error) {
+	v, ok := field.Tag.Lookup(key)
+	if !ok {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, Errorf(f, nil, 0, "invalid %s tag of field %s: %q", key, field.Name, v)
+	}
+
+	return b, nil
+}
+
+// split a comma separated tag value
+func splitTagList(v string) (list []string) {
+	for _, term := range strings.Split(v, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			list = append(list, term)
+		}
+	}
+	return list
+}
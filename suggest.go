@@ -0,0 +1,130 @@
+package xflag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// edit distance between a and b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < curr[j] {
+				curr[j] = d
+			}
+			if d := curr[j-1] + 1; d < curr[j] {
+				curr[j] = d
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// return candidates close to name, nearest first
+func closest(name string, candidates []string) (similar []string) {
+	distances := make(map[string]int)
+	for _, c := range candidates {
+		if _, has := distances[c]; has {
+			continue
+		}
+
+		d := levenshtein(strings.ToLower(name), strings.ToLower(c))
+		if d <= 2 || (len(name) > 1 && strings.HasPrefix(c, name)) {
+			distances[c] = d
+			similar = append(similar, c)
+		}
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		return distances[similar[i]] < distances[similar[j]]
+	})
+
+	return similar
+}
+
+// error of an undefined flag, with suggestions from f, its inherited
+// persistent flags, and flags of the same name on ancestors and descendants
+func (f *FlagSet) undefinedFlagError(arg string) *Error {
+	var suggestions []string
+
+	if strings.HasPrefix(arg, "--") {
+		var names []string
+		for fs := f; fs != nil; fs = fs.parent {
+			fs.visit(func(flag *Flag) error {
+				if (fs == f || flag.Persistent) && fs.isVisible(flag) {
+					_, longs := flag.names()
+					names = append(names, longs...)
+				}
+				return nil
+			})
+		}
+		suggestions = closest(arg, names)
+	}
+
+	if len(suggestions) == 0 {
+		for fs := f.parent; fs != nil; fs = fs.parent {
+			if flag := fs.lookup(arg); flag != nil && fs.isVisible(flag) {
+				suggestions = append(suggestions, fmt.Sprintf("%s %s", fs.Name, arg))
+			}
+		}
+
+		f.visitCommands(func(fs *FlagSet) {
+			if flag := fs.lookup(arg); flag != nil && fs.isVisible(flag) {
+				suggestions = append(suggestions, fmt.Sprintf("%s %s", fs.Name, arg))
+			}
+		})
+	}
+
+	err := Errorf(f, nil, ERROR_UNDEFINED_FLAG, "%s flag is undefined%s", arg, didYouMean(suggestions))
+	err.Suggestions = suggestions
+	return err
+}
+
+// error of an unknown sub-command, with suggestions from names and aliases
+func (f *FlagSet) unknownCommandError(name string) *Error {
+	var names []string
+	for _, cmd := range f.cmds {
+		if !cmd.Hidden {
+			names = append(names, cmd.Name)
+			names = append(names, cmd.Aliases...)
+		}
+	}
+
+	suggestions := closest(name, names)
+	err := Errorf(f, nil, 0, "unkown command: %s%s", name, didYouMean(suggestions))
+	err.Suggestions = suggestions
+	return err
+}
+
+// visit visible descendant commands
+func (f *FlagSet) visitCommands(fn func(fs *FlagSet)) {
+	for _, cmd := range f.cmds {
+		if !cmd.Hidden {
+			fn(cmd)
+			cmd.visitCommands(fn)
+		}
+	}
+}
+
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, " or "))
+}
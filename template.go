@@ -0,0 +1,215 @@
+package xflag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// whether the flag is written to config templates
+func (f *FlagSet) isTemplateFlag(flag *Flag) bool {
+	return !flag.Hidden && !flag.Secret && flag != f.configFlag
+}
+
+// config key of the flag
+func (flag *Flag) configKey() string {
+	if flag.Long == "" {
+		return flag.Short
+	}
+	return flag.Long
+}
+
+// return the default values of a flag, DefValue or the bound value.
+// slices and maps taken from struct fields are read from the variable.
+func templateValues(flag *Flag) []string {
+	if flag.DefValue != "" && !(flag.defFromField && isMultiValue(flag)) {
+		return []string{flag.DefValue}
+	}
+	return flagValues(flag)
+}
+
+// encode a value as JSON, numbers and booleans are kept unquoted
+func jsonValue(flag *Flag, value string) string {
+	t := reflect.TypeOf(flag.Value.Get())
+	if t == nil {
+		return strconv.Quote(value)
+	}
+
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if _, err := strconv.ParseBool(value); err == nil {
+			return strings.ToLower(value)
+		}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		// time.Duration is written as a string like "1m0s"
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value
+		}
+	}
+
+	return strconv.Quote(value)
+}
+
+// write help lines of a flag as comments
+func writeTemplateHelp(w *bufio.Writer, indent, comment string, flag *Flag) {
+	lines := splitHelp(flag.Help)
+	if flag.Required {
+		lines = append(lines, "(required)")
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s%s %s\n", indent, comment, line)
+	}
+}
+
+// write a JSON or INI config file with every flag set to its default
+// value, help text as comments and sub-commands in sections.
+// hidden and secret flags and hidden commands are left out.
+// JSON comments are "//" lines, accepted by BindConfig.
+func (f *FlagSet) WriteConfigTemplate(w io.Writer, format ConfigFormat) error {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+
+	switch format {
+	case ConfigJSON:
+		f.writeJSONTemplate(bw, "")
+		fmt.Fprintln(bw)
+	case ConfigINI:
+		f.writeINITemplate(bw, "")
+	default:
+		return Errorf(f, nil, ERROR_CONFIG, "unsupported config format: %d", format)
+	}
+
+	return bw.Flush()
+}
+
+func (f *FlagSet) writeJSONTemplate(w *bufio.Writer, indent string) {
+	var members []func()
+
+	f.visit(func(flag *Flag) error {
+		if !f.isTemplateFlag(flag) {
+			return nil
+		}
+
+		members = append(members, func() {
+			writeTemplateHelp(w, indent+"  ", "//", flag)
+
+			values := templateValues(flag)
+			key := strconv.Quote(flag.configKey())
+
+			switch reflect.ValueOf(flag.Value.Get()).Kind() {
+			case reflect.Map:
+				var pairs []string
+				for _, kv := range values {
+					terms := strings.SplitN(kv, "=", 2)
+					if len(terms) == 2 {
+						pairs = append(pairs, fmt.Sprintf("%s: %s", strconv.Quote(terms[0]), strconv.Quote(terms[1])))
+					}
+				}
+				fmt.Fprintf(w, "%s  %s: {%s}", indent, key, strings.Join(pairs, ", "))
+
+			case reflect.Slice:
+				var elems []string
+				for _, value := range values {
+					elems = append(elems, jsonValue(flag, value))
+				}
+				fmt.Fprintf(w, "%s  %s: [%s]", indent, key, strings.Join(elems, ", "))
+
+			case reflect.Invalid:
+				// Get returned nil and there is no DefValue
+				if len(values) == 0 {
+					fmt.Fprintf(w, "%s  %s: null", indent, key)
+					break
+				}
+				fmt.Fprintf(w, "%s  %s: %s", indent, key, jsonValue(flag, values[0]))
+
+			default:
+				fmt.Fprintf(w, "%s  %s: %s", indent, key, jsonValue(flag, values[0]))
+			}
+		})
+		return nil
+	})
+
+	for _, cmd := range f.cmds {
+		if cmd.Hidden {
+			continue
+		}
+
+		cmd := cmd
+		members = append(members, func() {
+			if cmd.Description != "" {
+				fmt.Fprintf(w, "%s  // %s\n", indent, cmd.Description)
+			}
+			fmt.Fprintf(w, "%s  %s: ", indent, strconv.Quote(cmd.Name))
+			cmd.writeJSONTemplate(w, indent+"  ")
+		})
+	}
+
+	fmt.Fprintf(w, "{\n")
+	for i, member := range members {
+		member()
+		if i < len(members)-1 {
+			fmt.Fprintf(w, ",")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	fmt.Fprintf(w, "%s}", indent)
+}
+
+// quote INI values which would be changed by reading them back
+func iniValue(value string) string {
+	if value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func (f *FlagSet) writeINITemplate(w *bufio.Writer, section string) {
+	if section != "" {
+		fmt.Fprintf(w, "\n")
+		if f.Description != "" {
+			fmt.Fprintf(w, "; %s\n", f.Description)
+		}
+		fmt.Fprintf(w, "[%s]\n", section)
+	}
+
+	f.visit(func(flag *Flag) error {
+		if !f.isTemplateFlag(flag) {
+			return nil
+		}
+
+		writeTemplateHelp(w, "", ";", flag)
+
+		values := templateValues(flag)
+		if len(values) == 0 {
+			fmt.Fprintf(w, "; %s =\n", flag.configKey())
+		}
+
+		for _, value := range values {
+			fmt.Fprintf(w, "%s = %s\n", flag.configKey(), iniValue(value))
+		}
+		return nil
+	})
+
+	for _, cmd := range f.cmds {
+		if cmd.Hidden {
+			continue
+		}
+
+		name := cmd.Name
+		if section != "" {
+			name = section + "." + name
+		}
+		cmd.writeINITemplate(w, name)
+	}
+}
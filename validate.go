@@ -0,0 +1,215 @@
+package xflag
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator checks the value returned by Value.Get after parsing.
+// validators run only for flags which were set or have DefValue, absent
+// flags are not validated; use Required to demand a value.
+type Validator func(value interface{}) error
+
+// run validators of the flags set or defaulted
+func (f *FlagSet) checkValidators(st *parseState) (err error) {
+	return f.visit(func(flag *Flag) error {
+		if !flag.IsSet && flag.DefValue == "" {
+			return nil
+		}
+
+		value := flag.Value.Get()
+		for _, validator := range flag.Validators {
+			if verr := validator(value); verr != nil {
+				e := Errorf(f, flag, ERROR_INVALID_VALUE, "invalid value %s: %w", formatValue(value), verr)
+				e.Value = formatValue(value)
+				return st.fail(e)
+			}
+		}
+		return nil
+	})
+}
+
+// return the length of strings, slices and maps
+func valueLen(v reflect.Value) (n int, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// return a number for comparison, bound is parsed as a duration for time.Duration
+func valueNumber(v reflect.Value) (n float64, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func parseBound(t reflect.Type, s string) (float64, error) {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		return float64(d), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// min:"1", max:"10"; numbers are compared by value, others by length
+func boundValidator(t reflect.Type, s string, isMin bool) (Validator, error) {
+	bound, err := parseBound(t, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}) error {
+		v := reflect.ValueOf(value)
+
+		n, ok := valueNumber(v)
+		what := "value"
+		if !ok {
+			var l int
+			if l, ok = valueLen(v); !ok {
+				return fmt.Errorf("unsupported type for bounds: %T", value)
+			}
+			n, what = float64(l), "length"
+		}
+
+		if isMin && n < bound {
+			return fmt.Errorf("%s must be at least %s", what, s)
+		}
+		if !isMin && n > bound {
+			return fmt.Errorf("%s must be at most %s", what, s)
+		}
+		return nil
+	}, nil
+}
+
+// len:"3"
+func lenValidator(s string) (Validator, error) {
+	want, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}) error {
+		l, ok := valueLen(reflect.ValueOf(value))
+		if !ok {
+			return fmt.Errorf("unsupported type for len: %T", value)
+		}
+		if l != want {
+			return fmt.Errorf("length must be %d", want)
+		}
+		return nil
+	}, nil
+}
+
+// apply fn to a string or each element of a slice
+func eachString(value interface{}, fn func(s string) error) error {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := fn(fmt.Sprint(v.Index(i).Interface())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fn(formatValue(value))
+	}
+}
+
+// pattern:"^[a-z]+$"
+func patternValidator(s string) (Validator, error) {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}) error {
+		return eachString(value, func(s string) error {
+			if !re.MatchString(s) {
+				return fmt.Errorf("%q does not match %s", s, re)
+			}
+			return nil
+		})
+	}, nil
+}
+
+// oneof:"json,yaml"
+func oneOfValidator(choices []string) Validator {
+	return func(value interface{}) error {
+		return eachString(value, func(s string) error {
+			if !matchFlagName(choices, s) {
+				return fmt.Errorf("%q is not one of %v", s, choices)
+			}
+			return nil
+		})
+	}
+}
+
+// nonempty:"true"
+func nonEmptyValidator(value interface{}) error {
+	if l, ok := valueLen(reflect.ValueOf(value)); ok && l == 0 {
+		return fmt.Errorf("value must not be empty")
+	}
+	return nil
+}
+
+// build validators from the bound tags of a struct field
+func tagValidators(f *FlagSet, field reflect.StructField, t reflect.Type) (validators []Validator, err error) {
+	invalid := func(key, v string, err error) error {
+		return Errorf(f, nil, 0, "invalid %s tag of field %s: %q: %v", key, field.Name, v, err)
+	}
+
+	for _, key := range []string{"min", "max"} {
+		if v, ok := field.Tag.Lookup(key); ok {
+			validator, err := boundValidator(t, v, key == "min")
+			if err != nil {
+				return nil, invalid(key, v, err)
+			}
+			validators = append(validators, validator)
+		}
+	}
+
+	if v, ok := field.Tag.Lookup("len"); ok {
+		validator, err := lenValidator(v)
+		if err != nil {
+			return nil, invalid("len", v, err)
+		}
+		validators = append(validators, validator)
+	}
+
+	if v, ok := field.Tag.Lookup("pattern"); ok {
+		validator, err := patternValidator(v)
+		if err != nil {
+			return nil, invalid("pattern", v, err)
+		}
+		validators = append(validators, validator)
+	}
+
+	if v, ok := field.Tag.Lookup("oneof"); ok {
+		validators = append(validators, oneOfValidator(splitTagList(v)))
+	}
+
+	nonEmpty, err := tagBool(f, field, "nonempty")
+	if err != nil {
+		return nil, err
+	}
+	if nonEmpty {
+		validators = append(validators, nonEmptyValidator)
+	}
+
+	return validators, nil
+}
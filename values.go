@@ -1,7 +1,9 @@
 package xflag
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,6 +11,14 @@ type boolTypeFlag interface {
 	IsBool() bool
 }
 
+// string form of a value returned by Value.Get
+func formatValue(v interface{}) string {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
+
 // bool
 
 type boolValue bool
@@ -107,6 +117,25 @@ func (l *stringSliceValue) Set(s string) error {
 
 func (l *stringSliceValue) Get() interface{} { return []string(*l) }
 
+// map[string]string
+
+type stringMapValue map[string]string
+
+func (m *stringMapValue) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected key=value: %q", s)
+	}
+
+	if *m == nil {
+		*m = make(stringMapValue)
+	}
+	(*m)[kv[0]] = kv[1]
+	return nil
+}
+
+func (m *stringMapValue) Get() interface{} { return map[string]string(*m) }
+
 // flat64
 
 type float64Value float64
@@ -1,11 +1,13 @@
 package xflag
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -23,6 +25,47 @@ type Flag struct {
 	DefValue  string
 	IsSet     bool
 	Completor func(args []string) (completes []string)
+
+	// satisfied when set or having DefValue
+	Required bool
+	// run on Value.Get() after parsing and applying defaults
+	Validators []Validator
+	// persistent flags are also accepted by all sub-commands below
+	Persistent bool
+
+	// additional names with dashes, registered by AddAlias and AddDeprecatedAlias
+	Aliases           []string
+	DeprecatedAliases []string
+
+	// hidden flags are parsed but never shown in help or completion
+	Hidden bool
+	// experimental flags are shown only by --help-all or XFLAG_EXPERIMENTAL=1
+	Experimental bool
+	// secret values, such as passwords, are masked by PrintValues and
+	// left out of config templates
+	Secret bool
+
+	// called in command line order whenever the flag is parsed.
+	// a returned error, such as ErrStopParsing, stops parsing and is
+	// returned by Parse unchanged.
+	Action func(flag *Flag, value string, fs *FlagSet) error
+
+	// environment variable read when the flag is not given,
+	// FlagSet.AutoEnv derives one from the long name if empty
+	EnvVar string
+
+	// where the value came from: OriginCommandLine, OriginDefault or a
+	// Source such as "env APP_PORT" and "config.json:12"
+	Origin string
+
+	// FlagSet defining the flag
+	fs *FlagSet
+	// variable bound by BindVar, restored by Reset
+	bound *binding
+	// DefValue is the value of the struct field at binding, which the
+	// variable already holds. slices and maps are cleared by the first
+	// explicit value.
+	defFromField bool
 }
 
 func (f *Flag) String() string {
@@ -37,23 +80,99 @@ func (f *Flag) String() string {
 	return fmt.Sprintf("Flag[%s]", strings.Join(flags, ","))
 }
 
+// a FlagSet is safe for concurrent use once its flags and sub-commands are
+// defined. Parse, Set and Reset lock the whole command tree for writing;
+// Visit, Snapshot, Clone, ToArgs, ToEnv, PrintValues, WriteConfigTemplate,
+// SubCommandName, Args, UnknownArgs and Result lock it for reading. Flag
+// only reads definitions. bound variables, fields of Flag, PrintHelp and
+// PrintDefaults are not locked, so they can be used from Actions; do not
+// use them while other goroutines parse or set, read values through
+// Snapshot instead. Actions, Validators, Validate hooks and Sources run
+// under the write lock and must not call the locking methods.
 type FlagSet struct {
 	Name  string
 	Usage string
 
 	// for sub-command
 	cmdName string
-	cmdSet  map[string]*FlagSet
+	cmdSet  map[string]*FlagSet // by name and alias
+	cmds    []*FlagSet          // in declaration order
+	parent  *FlagSet
+
+	// one-line description shown in the command list of the parent
+	Description string
+	// alternative command names, e.g. "rm" for "remove"
+	Aliases []string
+	// hidden commands are parsed but not listed in help or completion
+	Hidden bool
+	// heading the command is listed under in the help of the parent
+	Group string
+
+	// fail with ERROR_COMMAND_REQUIRED when no sub-command is given
+	RequireCommand bool
+	// sub-command selected when none is given
+	DefaultCommand string
+
+	// handler called by Execute when this command is selected
+	Run func(ctx context.Context, fs *FlagSet, args []string) error
 
 	// enable auto completion
 	EnableCompletion bool
 	// auto completion helper
 	Completor func(args []string) (completes []string)
 
+	// keep parsing after an error and return all of them as Errors
+	CollectErrors bool
+
+	// collect undefined flags into UnknownArgs instead of failing
+	PassUnknown bool
+
+	// read flags not given from environment variables named
+	// EnvPrefix_LONG_NAME, EnvPrefix defaults to Name for the top level
+	// FlagSet and PARENT_NAME for sub-commands
+	AutoEnv   bool
+	EnvPrefix string
+	// separator of slice and map values in environment variables, defaults to ","
+	EnvSeparator string
+
+	// enable --show-config, printing the effective values with their origins
+	EnableShowConfig bool
+
+	// format of the config file bound by BindConfig
+	ConfigFormat ConfigFormat
+	// ignore config keys matching no flag or sub-command
+	AllowUnknownConfigKeys bool
+
+	// normalize long flag names on definition and lookup, e.g. NormalizeName.
+	// set it before defining flags.
+	Normalize func(name string) string
+
+	// help flag names such as "-h" and "--help", defaults to "--help".
+	// help and version settings are inherited by sub-commands.
+	HelpFlags []string
+	// disable the help flag
+	DisableHelp bool
+
+	// enable the version flag, printing Version or the build information
+	EnableVersion bool
+	Version       string
+	// version flag names, defaults to "--version"
+	VersionFlags []string
+
 	// unexported variables
-	shortFlags map[string]*Flag
-	longFlags  map[string]*Flag
-	args       []string
+	flags       []*Flag // in definition order
+	shortFlags  map[string]*Flag
+	longFlags   map[string]*Flag
+	args        []string
+	unknown     []string
+	result      *ParseResult
+	helpAll     bool
+	constraints []*constraint
+	validators  []validator
+	configFlag  *Flag
+	configPath  string
+	sources     []Source
+	mu          sync.RWMutex // of the top level FlagSet guards the tree
 }
 
 func (f *FlagSet) PrintHelp() {
@@ -62,25 +181,44 @@ func (f *FlagSet) PrintHelp() {
 	fmt.Fprintf(os.Stderr, "Options:\n")
 	f.PrintDefaults()
 
-	if len(f.cmdSet) > 0 {
-		fmt.Fprintf(os.Stderr, "\nCommands:\n")
-		var cmds []string
-		for cmd := range f.cmdSet {
-			cmds = append(cmds, cmd)
-		}
-		sort.Sort(sort.StringSlice(cmds))
-		for _, cmd := range cmds {
-			fmt.Fprintf(os.Stderr, "  %s\n", cmd)
+	if global := f.globalFlags(); len(global) > 0 {
+		sort.Sort(flagSortByName(global))
+		fmt.Fprintf(os.Stderr, "\nGlobal Options:\n")
+		printFlags(global)
+	}
+
+	if len(f.constraints) > 0 {
+		fmt.Fprintf(os.Stderr, "\nConstraints:\n")
+		for _, c := range f.constraints {
+			fmt.Fprintf(os.Stderr, "  %s\n", c)
 		}
 	}
+
+	f.printCommands()
 }
 
-func (f *FlagSet) AddSubCommand(sub *FlagSet) {
+// add a sub-command, its name and aliases must not be used by another one
+func (f *FlagSet) AddSubCommand(sub *FlagSet) (err error) {
 	if f.cmdSet == nil {
 		f.cmdSet = make(map[string]*FlagSet)
 	}
 
-	f.cmdSet[sub.Name] = sub
+	names := append([]string{sub.Name}, sub.Aliases...)
+	for i, name := range names {
+		if other, has := f.cmdSet[name]; has || matchFlagName(names[:i], name) {
+			if other == nil {
+				other = sub
+			}
+			return Errorf(f, nil, 0, "command redefined: %s conflicts with %s", name, other.Name)
+		}
+	}
+
+	for _, name := range names {
+		f.cmdSet[name] = sub
+	}
+	f.cmds = append(f.cmds, sub)
+	sub.parent = f
+	return nil
 }
 
 func canFlagValue(v reflect.Value) (ok bool) {
@@ -107,54 +245,64 @@ func (f *FlagSet) BindVar(ifaceValue interface{}, short, long, defValue, help st
 
 	v = v.Elem()
 
-	ptr := v.UnsafeAddr()
+	value := newValue(v)
+	if value == nil {
+		return Errorf(f, nil, 0, "unsupported type: %v", v.Type())
+	}
+
+	err = f.setFlag(value, short, long, defValue, help)
+	if err != nil {
+		return err
+	}
+
+	f.flags[len(f.flags)-1].bound = newBinding(v)
+	return nil
+}
+
+// return the Value of the addressable variable v, nil for unsupported types
+func newValue(v reflect.Value) Value {
+	ptr := unsafe.Pointer(v.UnsafeAddr())
 
 	typeName := fmt.Sprintf("%s/%s", v.Type().PkgPath(), v.Type().Name())
 	kind := v.Kind()
 
-	var value Value
 	switch {
 	// interface Value
 	case canFlagValue(v):
-		value = v.Interface().(Value)
+		return v.Interface().(Value)
 	// time.Duration
 	case typeName == "time/Duration":
-		value = (*durationValue)(unsafe.Pointer(ptr))
+		return (*durationValue)(ptr)
 	// bool
 	case kind == reflect.Bool:
-		value = (*boolValue)(unsafe.Pointer(ptr))
+		return (*boolValue)(ptr)
 	// flat64
 	case kind == reflect.Float64:
-		value = (*float64Value)(unsafe.Pointer(ptr))
+		return (*float64Value)(ptr)
 	// int
 	case kind == reflect.Int:
-		value = (*intValue)(unsafe.Pointer(ptr))
+		return (*intValue)(ptr)
 	// int64
 	case kind == reflect.Int64:
-		value = (*int64Value)(unsafe.Pointer(ptr))
+		return (*int64Value)(ptr)
 	// uint
 	case kind == reflect.Uint:
-		value = (*uintValue)(unsafe.Pointer(ptr))
+		return (*uintValue)(ptr)
 	// uint64
 	case kind == reflect.Uint64:
-		value = (*uint64Value)(unsafe.Pointer(ptr))
+		return (*uint64Value)(ptr)
 	// string
 	case kind == reflect.String:
-		value = (*stringValue)(unsafe.Pointer(ptr))
+		return (*stringValue)(ptr)
 	// []bool
 	case kind == reflect.Slice && v.Type().Elem().Kind() == reflect.Bool:
-		value = (*boolSliceValue)(unsafe.Pointer(ptr))
+		return (*boolSliceValue)(ptr)
 	// []string
 	case kind == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
-		value = (*stringSliceValue)(unsafe.Pointer(ptr))
-	// error
-	default:
-		return Errorf(f, nil, 0, "unsupported type: %v", v.Type())
-	}
-
-	err = f.setFlag(value, short, long, defValue, help)
-	if err != nil {
-		return err
+		return (*stringSliceValue)(ptr)
+	// map[string]string
+	case kind == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String:
+		return (*stringMapValue)(ptr)
 	}
 
 	return nil
@@ -194,10 +342,6 @@ func (f *FlagSet) setFlag(value Value, short, long, defValue, help string) (err
 		metaVar = "VALUE"
 	}
 
-	if long == "help" {
-		Errorf(f, nil, 0, "reserved flag name used: --help")
-	}
-
 	flag := &Flag{
 		Short:    short,
 		Long:     long,
@@ -206,12 +350,17 @@ func (f *FlagSet) setFlag(value Value, short, long, defValue, help string) (err
 		Value:    value,
 		DefValue: defValue,
 		IsSet:    false,
+		fs:       f,
 	}
 
 	if short == "" && long == "" {
 		return Errorf(f, flag, 0, "flag name undefined")
 	}
 
+	if name := f.reservedName(short, long); name != "" {
+		return Errorf(f, flag, 0, "reserved flag name used: %s", name)
+	}
+
 	if short != "" {
 		if _, has := f.shortFlags[short]; has {
 			return Errorf(f, flag, 0, "short flag redefined")
@@ -220,15 +369,30 @@ func (f *FlagSet) setFlag(value Value, short, long, defValue, help string) (err
 	}
 
 	if long != "" {
-		if _, has := f.longFlags[long]; has {
-			return Errorf(f, flag, 0, "long flag redefined")
+		if other, has := f.longFlags[f.longKey(long)]; has {
+			return Errorf(f, flag, 0, "long flag redefined: --%s conflicts with %s", long, other.name())
 		}
-		f.longFlags[long] = flag
+		f.longFlags[f.longKey(long)] = flag
 	}
 
+	f.flags = append(f.flags, flag)
+
 	return nil
 }
 
+// NormalizeName makes long flag names case insensitive and treats '_' as '-'
+func NormalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// return the key of a long flag name in longFlags
+func (f *FlagSet) longKey(name string) string {
+	if f.Normalize == nil {
+		return name
+	}
+	return f.Normalize(name)
+}
+
 func (f *FlagSet) Flag(name string) *Flag {
 	if strings.HasPrefix(name, "--") {
 		name = name[2:]
@@ -236,7 +400,7 @@ func (f *FlagSet) Flag(name string) *Flag {
 		name = name[1:]
 	}
 
-	if flag, ok := f.longFlags[name]; ok {
+	if flag, ok := f.longFlags[f.longKey(name)]; ok {
 		return flag
 	} else if flag, ok := f.shortFlags[name]; ok {
 		return flag
@@ -245,30 +409,33 @@ func (f *FlagSet) Flag(name string) *Flag {
 	return nil
 }
 
-// visit all flag
+// visit all flag, fn must not call Parse, Set or Reset
 func (f *FlagSet) Visit(fn func(*Flag) error) error {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return f.visit(fn)
+}
+
+func (f *FlagSet) visit(fn func(*Flag) error) error {
 	var err error
 
-	for _, f := range f.shortFlags {
+	for _, f := range f.flags {
 		err = fn(f)
 		if err != nil {
 			return err
 		}
 	}
 
-	for _, f := range f.longFlags {
-		if f.Short == "" {
-			err = fn(f)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
 	return nil
 }
 
 func (f *FlagSet) SubCommandName() (name string) {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
 	return f.cmdName
 }
 
@@ -279,37 +446,163 @@ func (f *FlagSet) SubCommandName() (name string) {
 // --flag       // only boolean
 // --flag=value // any type
 // --flag value // without boolean
+// how the arguments were interpreted is recorded in Result().
+// call Reset before parsing again.
 func (f *FlagSet) Parse(arguments []string) (err error) {
+	mu := f.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
 	defer func() {
 		if f.EnableCompletion {
 			doCompletion(f)
 		}
 	}()
 
-	err = f.flagParse(arguments)
+	err = f.checkReserved()
+	if err != nil {
+		return err
+	}
+
+	st := &parseState{
+		result:  &ParseResult{},
+		collect: f.CollectErrors,
+	}
+
+	err = f.parse(arguments, st)
+	if err != nil {
+		return err
+	}
+
+	// the chain is complete, values of persistent flags are known
+	// command line > environment > sources > default
+	for _, fs := range st.chain {
+		err = fs.loadSources(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, fs := range st.chain {
+		err = fs.applySources(st.chain[:i+1], st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, fs := range st.chain {
+		err = fs.applyDefaults(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	if st.showConfig != nil {
+		return Errorf(st.showConfig, nil, ERROR_SHOW_CONFIG_REQUESTED, "")
+	}
+
+	for _, fs := range st.chain {
+		err = fs.checkRequired(st)
+		if err != nil {
+			return err
+		}
+
+		err = fs.checkValidators(st)
+		if err != nil {
+			return err
+		}
+
+		err = fs.checkConstraints(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the deepest sub-command is validated first
+	for i := len(st.chain) - 1; i >= 0; i-- {
+		err = st.chain[i].runValidators(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(st.errs) > 0 {
+		return st.errs
+	}
+
+	return nil
+}
+
+// state shared by the FlagSets taking part in one Parse
+type parseState struct {
+	offset int        // position of the current arguments in the top level argument list
+	chain  []*FlagSet // FlagSets from the top level to the selected sub-command
+	result *ParseResult
+	// top level FlagSet when the show config flag was given
+	showConfig *FlagSet
+	collect    bool
+	errs       Errors
+}
+
+// record err and keep going when errors are collected, otherwise return it
+func (st *parseState) fail(err *Error) error {
+	if st.collect {
+		st.errs = append(st.errs, err)
+		return nil
+	}
+	return err
+}
+
+// parse arguments recursively through the sub-commands
+func (f *FlagSet) parse(arguments []string, st *parseState) (err error) {
+	f.result = st.result
+	f.cmdName = ""
+	st.chain = append(st.chain, f)
+
+	err = f.flagParse(arguments, st)
 	if err != nil {
 		return err
 	}
 
-	subArgs := f.Args()
-	if len(f.cmdSet) > 0 && len(subArgs) >= 1 {
+	subArgs := f.args
+	switch {
+	case len(f.cmdSet) > 0 && len(subArgs) >= 1:
 		firstArg := subArgs[0]
 		subArgs = subArgs[1:]
 		if activeCommand, ok := f.cmdSet[firstArg]; ok {
-			f.cmdName = firstArg
-			err = activeCommand.Parse(subArgs)
-			if err != nil {
-				return err
-			}
+			st.offset += len(arguments) - len(subArgs)
+			return f.parseCommand(activeCommand, subArgs, st)
 		} else {
-			return Errorf(f, nil, 0, "unkown command: %s", firstArg)
+			return st.fail(f.unknownCommandError(firstArg))
+		}
+
+	case len(f.cmdSet) > 0 && f.DefaultCommand != "":
+		activeCommand, ok := f.cmdSet[f.DefaultCommand]
+		if !ok {
+			return Errorf(f, nil, 0, "unkown default command: %s", f.DefaultCommand)
 		}
+		st.offset += len(arguments)
+		return f.parseCommand(activeCommand, nil, st)
+
+	case len(f.cmdSet) > 0 && f.RequireCommand:
+		st.result.Args = []string{}
+		return st.fail(Errorf(f, nil, ERROR_COMMAND_REQUIRED, "command required, available commands: %s", strings.Join(f.commandNames(), ", ")))
+
+	default:
+		st.result.Args = append([]string{}, subArgs...)
 	}
 
 	return nil
 }
 
-func (f *FlagSet) flagParse(args []string) (err error) {
+// select the sub-command and parse its arguments
+func (f *FlagSet) parseCommand(cmd *FlagSet, arguments []string, st *parseState) error {
+	f.cmdName = cmd.Name
+	st.result.Commands = append(st.result.Commands, cmd.Name)
+	return cmd.parse(arguments, st)
+}
+
+func (f *FlagSet) flagParse(args []string, st *parseState) (err error) {
 	var (
 		isFinished = false
 		name       string
@@ -318,18 +611,37 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 		window     = args
 		shift      int
 		flag       *Flag
+		owner      *FlagSet // FlagSet defining flag, an ancestor for persistent flags
 		has        bool
 	)
 
+	f.args = args
+	f.unknown = nil
+	f.helpAll = false
+
 	for {
 		if len(window) == 0 || isFinished {
 			break
 		}
 
+		index := st.offset + len(args) - len(window)
+
 		switch {
-		case window[0] == "--help":
+		case matchFlagName(f.helpFlags(), window[0]):
+			return Errorf(f, nil, ERROR_HELP_REQUESTED, "")
+
+		case len(f.helpFlags()) > 0 && window[0] == helpAllFlag:
+			f.helpAll = true
 			return Errorf(f, nil, ERROR_HELP_REQUESTED, "")
 
+		case matchFlagName(f.versionFlags(), window[0]):
+			return Errorf(f, nil, ERROR_VERSION_REQUESTED, "")
+
+		case f.isShowConfig(window[0]):
+			// printed after all values are resolved
+			st.showConfig = st.chain[0]
+			window = window[1:]
+
 		case window[0] == "--":
 			// -- terminator
 			window = window[1:]
@@ -341,9 +653,30 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 
 			// get flag name
 			name = terms[0]
-			if flag, has = f.longFlags[name]; !has {
-				return Errorf(f, nil, ERROR_UNDEFINED_FLAG, "--%s flag is undefined", name)
+			if flag, owner, has = f.lookupLong(name); !has && f.PassUnknown {
+				// --unknown=value, --unknown value or --unknown
+				shift = 1
+				if len(terms) == 1 && f.isUnknownValue(window[1:]) {
+					shift = 2
+				}
+				f.unknown = append(f.unknown, window[:shift]...)
+				window = window[shift:]
+				break
+			} else if !has {
+				err = st.fail(f.undefinedFlagError("--" + name))
+				if err != nil {
+					return err
+				}
+
+				// skip the likely value, so later flags are still parsed
+				shift = 1
+				if len(terms) == 1 && f.isUnknownValue(window[1:]) {
+					shift = 2
+				}
+				window = window[shift:]
+				break
 			}
+			owner.warnDeprecated(flag, "--"+name)
 
 			// check boolean field
 			if boolFlag, ok := flag.Value.(boolTypeFlag); ok && boolFlag.IsBool() {
@@ -366,12 +699,16 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 				value = window[1]
 				shift = 2
 			} else {
-				return Errorf(f, flag, ERROR_EMPTY_VALUE, "--%s flag value was not provied", name)
+				err = st.fail(Errorf(f, flag, ERROR_EMPTY_VALUE, "--%s flag value was not provied", name))
+				if err != nil {
+					return err
+				}
+				window = window[1:]
+				break
 			}
 
 			// set Value
-			flag.IsSet = true
-			err = flag.Value.Set(value)
+			err = owner.setValue(flag, window[0], index, value, st)
 			if err != nil {
 				return err
 			}
@@ -388,9 +725,26 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 				}
 				// get flag
 				name = string(opt[0])
-				if flag, has = f.shortFlags[name]; !has {
-					return Errorf(f, nil, ERROR_UNDEFINED_FLAG, "-%s flag is undefined", name)
+				if flag, owner, has = f.lookupShort(name); !has && f.PassUnknown {
+					// pass the rest of the cluster with the likely value
+					f.unknown = append(f.unknown, "-"+opt)
+					if len(opt) == 1 && f.isUnknownValue(window[1:]) {
+						f.unknown = append(f.unknown, window[1])
+						shift = 2
+					}
+					break
+				} else if !has {
+					err = st.fail(f.undefinedFlagError("-" + name))
+					if err != nil {
+						return err
+					}
+					if len(opt) == 1 && f.isUnknownValue(window[1:]) {
+						shift = 2
+					}
+					opt = opt[1:]
+					continue
 				}
+				owner.warnDeprecated(flag, "-"+name)
 
 				// check boolean field
 				if boolFlag, ok := flag.Value.(boolTypeFlag); ok && boolFlag.IsBool() {
@@ -414,12 +768,15 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 					opt = opt[1:]
 					shift = 2
 				} else {
-					return Errorf(f, flag, ERROR_EMPTY_VALUE, "-%s flag value was not provided", name)
+					err = st.fail(Errorf(f, flag, ERROR_EMPTY_VALUE, "-%s flag value was not provided", name))
+					if err != nil {
+						return err
+					}
+					break
 				}
 
 				// set value
-				flag.IsSet = true
-				err = flag.Value.Set(value)
+				err = owner.setValue(flag, window[0], index, value, st)
 				if err != nil {
 					return err
 				}
@@ -433,12 +790,24 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 		f.args = window
 	}
 
-	// set default values
-	err = f.Visit(func(f *Flag) error {
-		if !f.IsSet && f.DefValue != "" {
-			err = f.Value.Set(f.DefValue)
+	return nil
+}
+
+// set default values of flags not given
+func (f *FlagSet) applyDefaults(st *parseState) (err error) {
+	err = f.visit(func(flag *Flag) error {
+		if !flag.IsSet && flag.DefValue != "" {
+			flag.Origin = OriginDefault
+			if flag.defFromField {
+				// setting it again would append to slices and maps
+				return nil
+			}
+
+			err := flag.Value.Set(flag.DefValue)
 			if err != nil {
-				return err
+				e := Errorf(f, flag, ERROR_INVALID_VALUE, "invalid default value %q: %w", flag.DefValue, err)
+				e.Value = flag.DefValue
+				return st.fail(e)
 			}
 		}
 		return nil
@@ -451,11 +820,62 @@ func (f *FlagSet) flagParse(args []string) (err error) {
 	return nil
 }
 
+// whether the next argument looks like the value of an undefined flag
+func (f *FlagSet) isUnknownValue(window []string) bool {
+	if len(window) == 0 || strings.HasPrefix(window[0], "-") {
+		return false
+	}
+
+	_, isCommand := f.cmdSet[window[0]]
+	return !isCommand
+}
+
+// set a value parsed from the command line and record the occurrence
+func (f *FlagSet) setValue(flag *Flag, token string, index int, value string, st *parseState) (err error) {
+	flag.clearFieldDefault()
+	flag.IsSet = true
+	flag.Origin = OriginCommandLine
+	err = flag.Value.Set(value)
+	if err != nil {
+		e := Errorf(f, flag, ERROR_INVALID_VALUE, "invalid value %q: %w", value, err)
+		e.Value = value
+		return st.fail(e)
+	}
+
+	st.result.Occurrences = append(st.result.Occurrences, Occurrence{
+		Flag:  flag,
+		Token: token,
+		Index: index,
+		Value: value,
+	})
+
+	if flag.Action != nil {
+		return flag.Action(flag, value, f)
+	}
+
+	return nil
+}
+
 // return remained arguments
 func (f *FlagSet) Args() []string {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
 	return f.args
 }
 
+// return undefined flags collected by PassUnknown in command line order.
+// they precede Args(), so append(f.UnknownArgs(), f.Args()...) forwards
+// the arguments unchanged.
+func (f *FlagSet) UnknownArgs() []string {
+	mu := f.mutex()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return f.unknown
+}
+
 type flagSortByName []*Flag
 
 func (a flagSortByName) Len() int      { return len(a) }
@@ -485,14 +905,40 @@ func (f *FlagSet) PrintDefaults() {
 	const format = "  %-30s  %s\n"
 	var flags []*Flag
 
-	f.Visit(func(f *Flag) error {
-		flags = append(flags, f)
+	var hasExperimental bool
+	f.visit(func(flag *Flag) error {
+		if flag.Experimental {
+			hasExperimental = true
+		}
+		if f.isVisible(flag) {
+			flags = append(flags, flag)
+		}
 		return nil
 	})
 
 	sort.Sort(flagSortByName(flags))
 
-	fmt.Fprintf(os.Stderr, format, "    --help", "print this message")
+	if names := f.helpFlags(); len(names) > 0 {
+		fmt.Fprintf(os.Stderr, format, builtinFlagColumn(names), "print this message")
+		if hasExperimental {
+			fmt.Fprintf(os.Stderr, format, builtinFlagColumn([]string{helpAllFlag}), "print this message with experimental options")
+		}
+	}
+
+	if names := f.versionFlags(); len(names) > 0 {
+		fmt.Fprintf(os.Stderr, format, builtinFlagColumn(names), "print version information")
+	}
+
+	if f.EnableShowConfig {
+		fmt.Fprintf(os.Stderr, format, builtinFlagColumn([]string{showConfigFlag}), "print effective values and their origins")
+	}
+
+	printFlags(flags)
+}
+
+// print flag names and help lines
+func printFlags(flags []*Flag) {
+	const format = "  %-30s  %s\n"
 
 	var short, long, metaVar string
 	for _, f := range flags {
@@ -502,29 +948,34 @@ func (f *FlagSet) PrintDefaults() {
 			metaVar = f.MetaVar
 		}
 
-		// long flag name formating
-		if f.Short != "" && metaVar != "" && f.Long == "" {
-			short = fmt.Sprintf("-%s %s", f.Short, metaVar)
-		} else if f.Short != "" {
-			short = fmt.Sprintf("-%s", f.Short)
-		} else {
-			short = "  "
+		// flag name formating
+		shorts, longs := f.names()
+		if metaVar != "" && len(longs) > 0 {
+			longs[len(longs)-1] += " " + metaVar
+		} else if metaVar != "" && len(shorts) > 0 {
+			shorts[len(shorts)-1] += " " + metaVar
 		}
 
-		// short flag name formating
-		if f.Long != "" && metaVar != "" {
-			long = fmt.Sprintf("--%s %s", f.Long, metaVar)
-		} else if f.Long != "" {
-			long = fmt.Sprintf("--%s", f.Long)
+		if len(shorts) > 0 {
+			short = strings.Join(shorts, "  ")
 		} else {
-			long = ""
+			short = "  "
 		}
+		long = strings.Join(longs, "  ")
 
 		lines := splitHelp(f.Help)
 		if f.DefValue != "" {
 			lines = append(lines, fmt.Sprintf("(default: %s)", f.DefValue))
 		}
 
+		if f.Required {
+			lines = append(lines, "(required)")
+		}
+
+		if env := f.envName(); env != "" {
+			lines = append(lines, fmt.Sprintf("(env: %s)", env))
+		}
+
 		if len(lines) == 0 {
 			lines = append(lines, "")
 		}
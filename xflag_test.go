@@ -1,6 +1,15 @@
 package xflag
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -238,3 +247,1735 @@ func TestXFlagParse(t *testing.T) {
 		}
 	}
 }
+
+func TestXFlagParseResult(t *testing.T) {
+	type Opt struct {
+		V    []bool `xflag:"v"`
+		Name string `xflag:"n,name"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subOpt := &SubOpt{}
+	sub := &FlagSet{Name: "sub"}
+	err = sub.BindStruct(subOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.AddSubCommand(sub)
+
+	err = fs.Parse([]string{
+		"-vv", "--name", "first", "--name=second", "sub", "-f", "arg",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := fs.Result()
+	if len(result.Occurrences) != 5 {
+		t.Fatalf("unexpected occurrences: %v", result.Occurrences)
+	}
+
+	names := result.Lookup("--name")
+	if len(names) != 2 {
+		t.Fatalf("unexpected occurrences: %v", names)
+	}
+
+	if names[0].Index != 1 || names[0].Token != "--name" || names[0].Value != "first" {
+		t.Errorf("unexpected occurrence: %+v", names[0])
+	}
+
+	if names[1].Index != 3 || names[1].Token != "--name=second" || names[1].Value != "second" {
+		t.Errorf("unexpected occurrence: %+v", names[1])
+	}
+
+	force := result.Lookup("f")
+	if len(force) != 1 || force[0].Index != 5 || force[0].Flag != sub.Flag("f") {
+		t.Errorf("unexpected occurrence: %+v", force)
+	}
+
+	if len(result.Commands) != 1 || result.Commands[0] != "sub" {
+		t.Errorf("unexpected commands: %v", result.Commands)
+	}
+
+	if len(result.Args) != 1 || result.Args[0] != "arg" {
+		t.Errorf("unexpected args: %v", result.Args)
+	}
+}
+
+func TestXFlagCollectErrors(t *testing.T) {
+	type Opt struct {
+		Number int    `xflag:"n,number"`
+		Name   string `xflag:"s,name,default"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt", CollectErrors: true}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Parse([]string{
+		"--undefined", "-n", "NaN", "-x", "--number",
+	})
+
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 4 {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+
+	codes := []ErrorCode{ERROR_UNDEFINED_FLAG, ERROR_INVALID_VALUE, ERROR_UNDEFINED_FLAG, ERROR_EMPTY_VALUE}
+	for i := range codes {
+		if errs[i].Code != codes[i] {
+			t.Errorf("unexpected error code: %v", errs[i])
+		}
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || e.Code != ERROR_UNDEFINED_FLAG {
+		t.Errorf("unexpected error: %v", e)
+	}
+
+	if opt.Name != "default" {
+		t.Error("default value was not applied")
+	}
+
+	// values of undefined flags are skipped and later flags are parsed
+	opt = &Opt{}
+	fs = &FlagSet{Name: "opt", CollectErrors: true}
+	fs.BindStruct(opt)
+
+	err = fs.Parse([]string{"--bogus", "v", "-x", "w", "--name", "x", "arg"})
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+
+	if opt.Name != "x" || fmt.Sprint(fs.Args()) != "[arg]" {
+		t.Errorf("later flags were not parsed: %q %v", opt.Name, fs.Args())
+	}
+}
+
+func TestXFlagPassUnknown(t *testing.T) {
+	type Opt struct {
+		V    bool   `xflag:"v"`
+		Name string `xflag:"n,name"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt", PassUnknown: true}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Parse([]string{
+		"--color", "always", "-v", "--name", "x", "-vx", "--depth=1", "-q", "file",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opt.V || opt.Name != "x" {
+		t.Error("value are incorrect")
+	}
+
+	unknown := fmt.Sprint(fs.UnknownArgs())
+	if unknown != "[--color always -x --depth=1 -q file]" {
+		t.Errorf("unexpected unknown args: %s", unknown)
+	}
+
+	if len(fs.Args()) != 0 {
+		t.Errorf("unexpected args: %v", fs.Args())
+	}
+}
+
+func TestXFlagHelpAndVersion(t *testing.T) {
+	{
+		fs := &FlagSet{Name: "opt", HelpFlags: []string{"-h", "--help"}}
+		if GetErrorCode(fs.Parse([]string{"-h"})) != ERROR_HELP_REQUESTED {
+			t.Error("-h was not recognized")
+		}
+
+		var host string
+		err := fs.BindVar(&host, "h", "host", "", "")
+		if GetErrorCode(err) != ERROR || err == nil {
+			t.Errorf("reserved name was accepted: %v", err)
+		}
+	}
+
+	{
+		var help bool
+		fs := &FlagSet{Name: "opt", DisableHelp: true}
+		err := fs.BindVar(&help, "", "help", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse([]string{"--help"})
+		if err != nil || !help {
+			t.Errorf("--help was not parsed as user flag: %v", err)
+		}
+	}
+
+	{
+		var version bool
+		fs := &FlagSet{Name: "opt"}
+		err := fs.BindVar(&version, "", "version", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs.EnableVersion = true
+		if err = fs.Parse([]string{}); err == nil {
+			t.Error("conflict with --version was not reported")
+		}
+	}
+
+	{
+		var host string
+		sub := &FlagSet{Name: "run"}
+		err := sub.BindVar(&host, "h", "host", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := &FlagSet{Name: "opt"}
+		fs.AddSubCommand(sub)
+
+		sub.HelpFlags = []string{"-h"}
+		err = fs.Parse([]string{"run", "-h"})
+		if err == nil || GetErrorCode(err) == ERROR_HELP_REQUESTED {
+			t.Errorf("conflict of sub-command with -h was not reported: %v", err)
+		}
+	}
+
+	// sub-commands inherit help and version flags
+	{
+		fs := &FlagSet{Name: "opt", HelpFlags: []string{"-h", "--help"}, EnableVersion: true, Version: "v1"}
+		sub := &FlagSet{Name: "run"}
+		fs.AddSubCommand(sub)
+
+		for _, args := range [][]string{{"-h"}, {"run", "-h"}, {"run", "--help"}} {
+			if err := fs.Parse(args); GetErrorCode(err) != ERROR_HELP_REQUESTED {
+				t.Errorf("%v: unexpected error: %v", args, err)
+			}
+		}
+
+		err := fs.Parse([]string{"run", "--version"})
+		if GetErrorCode(err) != ERROR_VERSION_REQUESTED || sub.versionString() != "v1" {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		fs.DisableHelp = true
+		if err := fs.Parse([]string{"run", "-h"}); GetErrorCode(err) != ERROR_UNDEFINED_FLAG {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	{
+		fs := &FlagSet{Name: "opt", EnableVersion: true, Version: "v1.2.3"}
+		if GetErrorCode(fs.Parse([]string{"--version"})) != ERROR_VERSION_REQUESTED {
+			t.Error("--version was not recognized")
+		}
+
+		if fs.versionString() != "v1.2.3" {
+			t.Errorf("unexpected version: %s", fs.versionString())
+		}
+	}
+}
+
+func TestXFlagAction(t *testing.T) {
+	type Opt struct {
+		Level string `xflag:"l,level"`
+		List  bool   `xflag:",list"`
+		After string `xflag:",after"`
+	}
+
+	var called []string
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Flag("level").Action = func(flag *Flag, value string, fs *FlagSet) error {
+		called = append(called, flag.Long+"="+value)
+		return nil
+	}
+
+	fs.Flag("list").Action = func(flag *Flag, value string, fs *FlagSet) error {
+		called = append(called, flag.Long)
+		return ErrStopParsing
+	}
+
+	err = fs.Parse([]string{
+		"-l", "debug", "--level=info", "--list", "--after", "x",
+	})
+	if err != ErrStopParsing {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fmt.Sprint(called) != "[level=debug level=info list]" {
+		t.Errorf("unexpected actions: %v", called)
+	}
+
+	if opt.After != "" {
+		t.Error("parsing was not stopped")
+	}
+}
+
+func TestXFlagAlias(t *testing.T) {
+	type Opt struct {
+		DryRun bool   `xflag:",dry-run" alias:"dryrun,n" deprecated:"dry_run"`
+		Output string `xflag:"o,output"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.AddDeprecatedAlias("output", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = fs.AddAlias("output", "dryrun"); err == nil {
+		t.Error("alias collision was not reported")
+	}
+
+	err = fs.Parse([]string{"--dry_run", "--out", "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opt.DryRun || opt.Output != "file" {
+		t.Error("value are incorrect")
+	}
+
+	if len(fs.Result().Lookup("dryrun")) != 1 {
+		t.Error("alias was not resolved in the result")
+	}
+
+	var count int
+	fs.Visit(func(*Flag) error {
+		count++
+		return nil
+	})
+	if count != 2 {
+		t.Errorf("aliases were visited: %d", count)
+	}
+
+	fs.EnableCompletion = true
+	words := fmt.Sprint(genComplWords(fs, []string{"-"}))
+	if words != "[-n  --dry-run  --dryrun  -o  --output ]" {
+		t.Errorf("unexpected completion: %s", words)
+	}
+}
+
+func TestXFlagHiddenFlag(t *testing.T) {
+	type Opt struct {
+		Debug bool `xflag:",debug" hidden:"true"`
+		Trace bool `xflag:",trace" experimental:"true"`
+		Quiet bool `xflag:"q,quiet"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Parse([]string{"--debug", "--trace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opt.Debug || !opt.Trace {
+		t.Error("value are incorrect")
+	}
+
+	words := fmt.Sprint(genComplWords(fs, []string{"-"}))
+	if words != "[-q  --quiet ]" {
+		t.Errorf("unexpected completion: %s", words)
+	}
+
+	if GetErrorCode(fs.Parse([]string{"--help-all"})) != ERROR_HELP_REQUESTED {
+		t.Fatal("--help-all was not recognized")
+	}
+
+	if !fs.isVisible(fs.Flag("trace")) || fs.isVisible(fs.Flag("debug")) {
+		t.Error("experimental flag should be visible with --help-all")
+	}
+}
+
+func TestXFlagRequired(t *testing.T) {
+	type Opt struct {
+		Host string `xflag:",host" required:"true"`
+		Port int    `xflag:"p,port" required:"true"`
+		User string `xflag:",user,root" required:"true"`
+	}
+
+	{
+		opt := &Opt{}
+		fs := &FlagSet{Name: "opt"}
+		err := fs.BindStruct(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse([]string{})
+		if GetErrorCode(err) != ERROR_REQUIRED_FLAG {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err.Error() != "FlagSet[opt]: required flags are missing: --host, --port" {
+			t.Errorf("unexpected message: %v", err)
+		}
+	}
+
+	{
+		opt := &Opt{}
+		fs := &FlagSet{Name: "opt"}
+		err := fs.BindStruct(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse([]string{"--host", "localhost", "-p", "22"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opt.User != "root" {
+			t.Error("value are incorrect")
+		}
+	}
+}
+
+func TestXFlagConstraint(t *testing.T) {
+	type Opt struct {
+		JSON bool   `xflag:",json" exclusive:"format"`
+		YAML bool   `xflag:",yaml" exclusive:"format"`
+		Cert string `xflag:",cert" together:"pair"`
+		Key  string `xflag:",key" together:"pair"`
+		TLS  bool   `xflag:",tls" requires:"ca"`
+		CA   string `xflag:",ca"`
+	}
+
+	tests := []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{"--json"}, ""},
+		{[]string{"--json", "--yaml"}, "FlagSet[opt]: --json, --yaml cannot be used together"},
+		{[]string{"--cert", "c"}, "FlagSet[opt]: --cert, --key must be used together, missing --key"},
+		{[]string{"--cert", "c", "--key", "k"}, ""},
+		{[]string{"--tls"}, "FlagSet[opt]: --tls requires --ca"},
+		{[]string{"--tls", "--ca", "ca"}, ""},
+	}
+
+	for _, test := range tests {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "opt"}
+		err := fs.BindStruct(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse(test.args)
+		if test.msg == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+		} else if test.msg != "" && (GetErrorCode(err) != ERROR_CONSTRAINT || err.Error() != test.msg) {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+		}
+	}
+
+	{
+		var src, url string
+		fs := &FlagSet{Name: "opt"}
+		fs.BindVar(&src, "", "src", "", "")
+		fs.BindVar(&url, "", "url", "", "")
+		if err := fs.RequireOneOf("src", "--url"); err != nil {
+			t.Fatal(err)
+		}
+
+		if GetErrorCode(fs.Parse([]string{})) != ERROR_CONSTRAINT {
+			t.Error("missing flag group was not reported")
+		}
+
+		if err := fs.MutuallyExclusive("src", "undefined"); GetErrorCode(err) != ERROR_UNDEFINED_FLAG {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	// defaults satisfy requires, together and at-least-one but never conflict
+	{
+		type DefOpt struct {
+			TLS    bool   `xflag:",tls" requires:"ca"`
+			CA     string `xflag:",ca,/etc/ca"`
+			Cert   string `xflag:",cert" together:"pair"`
+			Key    string `xflag:",key,key.pem" together:"pair"`
+			Src    string `xflag:",src,." atleastone:"source"`
+			URL    string `xflag:",url" atleastone:"source"`
+			Format string `xflag:",format,json" exclusive:"format"`
+			Output string `xflag:",output,out" exclusive:"format"`
+		}
+
+		fs := &FlagSet{Name: "opt"}
+		if err := fs.BindStruct(&DefOpt{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fs.Parse([]string{"--tls", "--cert", "c"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		fs.Reset()
+		err := fs.Parse([]string{"--format", "yaml", "--output", "x"})
+		if GetErrorCode(err) != ERROR_CONSTRAINT {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestXFlagValidator(t *testing.T) {
+	type Opt struct {
+		Port    int           `xflag:"p,port,8080" min:"1" max:"65535"`
+		Timeout time.Duration `xflag:",timeout" max:"1m"`
+		Code    string        `xflag:",code" len:"3" pattern:"^[A-Z]+$"`
+		Format  string        `xflag:",format" oneof:"json,yaml"`
+		Tags    []string      `xflag:",tag" nonempty:"true" oneof:"a,b"`
+	}
+
+	tests := []struct {
+		args  []string
+		flag  string
+		value string
+	}{
+		{[]string{"--tag", "a"}, "", ""},
+		{[]string{"--tag", "a", "-p", "0"}, "port", "0"},
+		{[]string{"--tag", "a", "--timeout", "2m"}, "timeout", "2m0s"},
+		{[]string{"--tag", "a", "--code", "ABCD"}, "code", "ABCD"},
+		{[]string{"--tag", "a", "--code", "abc"}, "code", "abc"},
+		{[]string{"--tag", "a", "--format", "xml"}, "format", "xml"},
+		{[]string{"--tag", "a", "--tag", "c"}, "tag", "[a c]"},
+		// absent flags are not validated
+		{[]string{}, "", ""},
+	}
+
+	for _, test := range tests {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "opt"}
+		err := fs.BindStruct(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse(test.args)
+		if test.flag == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", test.args, err)
+			}
+			continue
+		}
+
+		e := getError(err)
+		if e == nil || e.Code != ERROR_INVALID_VALUE || e.Flag != fs.Flag(test.flag) || e.Value != test.value {
+			t.Errorf("%v: unexpected error: %#v", test.args, e)
+		}
+	}
+
+	{
+		var n int
+		fs := &FlagSet{Name: "opt"}
+		fs.BindVar(&n, "n", "", "", "")
+		fs.Flag("n").Validators = append(fs.Flag("n").Validators, func(value interface{}) error {
+			if value.(int)%2 != 0 {
+				return errors.New("odd number")
+			}
+			return nil
+		})
+
+		if GetErrorCode(fs.Parse([]string{"-n", "3"})) != ERROR_INVALID_VALUE {
+			t.Error("validator was not called")
+		}
+	}
+
+	// Required demands a value of absent flags
+	{
+		type ReqOpt struct {
+			Name string `xflag:",name" nonempty:"true" required:"true"`
+		}
+
+		fs := &FlagSet{Name: "opt"}
+		fs.BindStruct(&ReqOpt{})
+		if GetErrorCode(fs.Parse([]string{})) != ERROR_REQUIRED_FLAG {
+			t.Error("absent flag was not reported")
+		}
+
+		fs.Reset()
+		if GetErrorCode(fs.Parse([]string{"--name", ""})) != ERROR_INVALID_VALUE {
+			t.Error("empty value was not reported")
+		}
+	}
+
+	{
+		type Opt struct {
+			Port int `xflag:"p" min:"one"`
+		}
+
+		fs := &FlagSet{Name: "opt"}
+		if err := fs.BindStruct(&Opt{}); err == nil {
+			t.Error("invalid tag was accepted")
+		}
+	}
+}
+
+type validateLog []string
+
+type serverOpt struct {
+	Host string `xflag:",host"`
+	log  *validateLog
+}
+
+func (o *serverOpt) Validate() error {
+	*o.log = append(*o.log, "server")
+	if o.Host == "" {
+		return errors.New("host is empty")
+	}
+	return nil
+}
+
+type rootOpt struct {
+	Server  serverOpt
+	Verbose bool `xflag:"v"`
+	log     *validateLog
+}
+
+func (o *rootOpt) Validate() error {
+	*o.log = append(*o.log, "root")
+	return nil
+}
+
+type subOpt struct {
+	Force bool `xflag:"f"`
+	log   *validateLog
+}
+
+func (o *subOpt) Validate() error {
+	*o.log = append(*o.log, "sub")
+	return nil
+}
+
+func TestXFlagValidateHook(t *testing.T) {
+	var log validateLog
+
+	opt := &rootOpt{Server: serverOpt{log: &log}, log: &log}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &FlagSet{Name: "sub"}
+	err = sub.BindStruct(&subOpt{log: &log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.AddSubCommand(sub)
+
+	err = fs.Parse([]string{"--host", "localhost", "sub", "-f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(log) != "[sub server root]" {
+		t.Errorf("unexpected validation order: %v", log)
+	}
+
+	err = fs.Parse([]string{"--host="})
+	if e := getError(err); e == nil || e.Code != ERROR_VALIDATION || e.FlagSet != fs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// structs without exported fields are not nested option structs
+	type timeOpt struct {
+		When time.Time `xflag:",when"`
+	}
+
+	type untaggedTimeOpt struct {
+		When time.Time
+	}
+
+	for _, opt := range []interface{}{&timeOpt{}, &untaggedTimeOpt{}} {
+		err = (&FlagSet{Name: "opt"}).BindStruct(opt)
+		if err == nil || !strings.Contains(err.Error(), "unsupported type") {
+			t.Errorf("%T: unexpected error: %v", opt, err)
+		}
+	}
+}
+
+func TestXFlagNormalize(t *testing.T) {
+	type Opt struct {
+		MaxConns int `xflag:",max-conns" deprecated:"MaxConnections"`
+	}
+
+	for _, arg := range []string{"--max-conns", "--Max_Conns", "--max_conns", "--maxconnections"} {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "opt", Normalize: NormalizeName}
+		err := fs.BindStruct(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fs.Parse([]string{arg, "10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opt.MaxConns != 10 {
+			t.Errorf("%s: value are incorrect", arg)
+		}
+
+		for _, name := range []string{"--Max_Conns", "max-conns", arg} {
+			if len(fs.Result().Lookup(name)) != 1 {
+				t.Errorf("%s: occurrence of %s was not found", arg, name)
+			}
+		}
+	}
+
+	{
+		var a, b int
+		fs := &FlagSet{Name: "opt", Normalize: NormalizeName}
+		fs.BindVar(&a, "", "max-conns", "", "")
+		err := fs.BindVar(&b, "", "max_conns", "", "")
+		if err == nil || err.Error() != "FlagSet[opt]:Flag[--max_conns]: long flag redefined: --max_conns conflicts with --max-conns" {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestXFlagPersistent(t *testing.T) {
+	type Opt struct {
+		Verbose bool   `xflag:"v,verbose" persistent:"true"`
+		Config  string `xflag:",config,default.conf" persistent:"true" required:"true"`
+		Local   bool   `xflag:",local"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f"`
+	}
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &FlagSet{Name: "sub"}
+	err = sub.BindStruct(&SubOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := &FlagSet{Name: "leaf"}
+	sub.AddSubCommand(leaf)
+	fs.AddSubCommand(sub)
+
+	err = fs.Parse([]string{"sub", "-vf", "leaf", "--config", "x.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opt.Verbose || opt.Config != "x.conf" {
+		t.Error("value are incorrect")
+	}
+
+	if o := fs.Result().Lookup("config"); len(o) != 1 || o[0].Index != 3 {
+		t.Errorf("unexpected occurrence: %v", o)
+	}
+
+	err = fs.Parse([]string{"sub", "--local"})
+	if GetErrorCode(err) != ERROR_UNDEFINED_FLAG {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(leaf.globalFlags()) != 2 {
+		t.Errorf("unexpected global flags: %v", leaf.globalFlags())
+	}
+}
+
+func TestXFlagSubCommand(t *testing.T) {
+	fs := &FlagSet{Name: "opt"}
+	fs.AddSubCommand(&FlagSet{Name: "remove", Aliases: []string{"rm"}, Description: "remove files"})
+	fs.AddSubCommand(&FlagSet{Name: "debug", Hidden: true})
+	fs.AddSubCommand(&FlagSet{Name: "add", Description: "add files"})
+
+	// names and aliases of other commands are rejected
+	for _, cmd := range []*FlagSet{
+		{Name: "add"},
+		{Name: "delete", Aliases: []string{"rm"}},
+		{Name: "plus", Aliases: []string{"add"}},
+		{Name: "list", Aliases: []string{"ls", "ls"}},
+	} {
+		if err := fs.AddSubCommand(cmd); err == nil || cmd.parent != nil {
+			t.Errorf("%s: conflict was not reported", cmd.Name)
+		}
+	}
+
+	err := fs.Parse([]string{"add"})
+	if err != nil || fs.SubCommandName() != "add" {
+		t.Errorf("unexpected command: %s, %v", fs.SubCommandName(), err)
+	}
+
+	err = fs.Parse([]string{"rm", "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.SubCommandName() != "remove" || fs.Result().Commands[0] != "remove" {
+		t.Errorf("alias was not resolved: %s", fs.SubCommandName())
+	}
+
+	err = fs.Parse([]string{"debug"})
+	if err != nil || fs.SubCommandName() != "debug" {
+		t.Errorf("hidden command was not parsed: %v", err)
+	}
+
+	fs.cmdName = ""
+	words := fmt.Sprint(genComplWords(fs, []string{""}))
+	if words != "[remove add]" {
+		t.Errorf("unexpected completion: %s", words)
+	}
+}
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestXFlagExecute(t *testing.T) {
+	var called string
+
+	fs := &FlagSet{Name: "opt"}
+	remote := &FlagSet{Name: "remote"}
+	add := &FlagSet{Name: "add"}
+	list := &FlagSet{Name: "list"}
+	remote.AddSubCommand(add)
+	remote.AddSubCommand(list)
+	fs.AddSubCommand(remote)
+
+	add.Run = func(ctx context.Context, fs *FlagSet, args []string) error {
+		called = fmt.Sprint(fs.Name, args)
+		return exitError(3)
+	}
+	remote.Run = func(ctx context.Context, fs *FlagSet, args []string) error {
+		called = fmt.Sprint(fs.Name, args)
+		return nil
+	}
+
+	err := fs.Execute(context.Background(), []string{"remote", "add", "origin"})
+	if called != "add[origin]" || ExitCode(err) != 3 {
+		t.Errorf("unexpected handler call: %s, %v", called, err)
+	}
+
+	err = fs.Execute(context.Background(), []string{"remote", "list"})
+	if called != "remote[list]" || err != nil {
+		t.Errorf("unexpected handler call: %s, %v", called, err)
+	}
+
+	err = fs.Execute(context.Background(), []string{"remote"})
+	if called != "remote[]" || err != nil {
+		t.Errorf("unexpected handler call: %s, %v", called, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called = ""
+	err = fs.Execute(ctx, []string{"remote", "add"})
+	if called != "" || ExitCode(err) != 130 {
+		t.Errorf("cancelled context was not honored: %v", err)
+	}
+
+	if ExitCode(fs.Execute(context.Background(), []string{"--undefined"})) != 2 {
+		t.Error("unexpected exit code of usage error")
+	}
+
+	if ExitCode(fs.Execute(context.Background(), []string{"--help"})) != 0 {
+		t.Error("unexpected exit code of help")
+	}
+}
+
+func TestXFlagCommandRequired(t *testing.T) {
+	fs := &FlagSet{Name: "opt", RequireCommand: true}
+	fs.AddSubCommand(&FlagSet{Name: "status", Completor: func(args []string) []string {
+		return []string{"file"}
+	}})
+	fs.AddSubCommand(&FlagSet{Name: "commit"})
+	fs.AddSubCommand(&FlagSet{Name: "debug", Hidden: true})
+
+	err := fs.Parse([]string{})
+	if GetErrorCode(err) != ERROR_COMMAND_REQUIRED {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err.Error() != "FlagSet[opt]: command required, available commands: status, commit" {
+		t.Errorf("unexpected message: %v", err)
+	}
+
+	fs.DefaultCommand = "status"
+	err = fs.Parse([]string{})
+	if err != nil || fs.SubCommandName() != "status" {
+		t.Errorf("default command was not selected: %v", err)
+	}
+
+	// completion of the default command, not given on the command line
+	for _, args := range [][]string{{}, {"-"}} {
+		fs.Parse(args)
+		words := fmt.Sprint(genComplWords(fs, args))
+		if fs.SubCommandName() != "status" || words != "[file]" {
+			t.Errorf("%q: unexpected completion: %s", args, words)
+		}
+	}
+
+	err = fs.Parse([]string{"commit"})
+	if err != nil || fs.SubCommandName() != "commit" {
+		t.Errorf("unexpected command: %v", err)
+	}
+}
+
+func TestXFlagSuggestion(t *testing.T) {
+	type Opt struct {
+		Verbose bool `xflag:"v,verbose"`
+		Debug   bool `xflag:",debug"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f,force"`
+	}
+
+	fs := &FlagSet{Name: "opt"}
+	err := fs.BindStruct(&Opt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &FlagSet{Name: "remove", Aliases: []string{"rm"}}
+	err = sub.BindStruct(&SubOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.AddSubCommand(sub)
+
+	tests := []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{"--verbos"}, "FlagSet[opt]: --verbos flag is undefined, did you mean --verbose?"},
+		{[]string{"--force"}, "FlagSet[opt]: --force flag is undefined, did you mean remove --force?"},
+		{[]string{"remove", "--debug"}, "FlagSet[remove]: --debug flag is undefined, did you mean opt --debug?"},
+		{[]string{"remov"}, "FlagSet[opt]: unkown command: remov, did you mean remove?"},
+		{[]string{"--xyz"}, "FlagSet[opt]: --xyz flag is undefined"},
+	}
+
+	for _, test := range tests {
+		err = fs.Parse(test.args)
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+		}
+	}
+
+	if e := getError(fs.Parse([]string{"--verbos"})); len(e.Suggestions) != 1 || e.Suggestions[0] != "--verbose" {
+		t.Errorf("unexpected suggestions: %v", e.Suggestions)
+	}
+}
+
+func TestXFlagEnv(t *testing.T) {
+	type Opt struct {
+		Port   int               `xflag:"p,port,80" env:"APP_PORT"`
+		Host   string            `xflag:",host,localhost"`
+		Tags   []string          `xflag:",tag"`
+		Labels map[string]string `xflag:",label"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f,force"`
+	}
+
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("APP_HOST", "example.com")
+	t.Setenv("APP_TAG", "a;b")
+	t.Setenv("APP_LABEL", "k1=v1;k2=v2")
+	t.Setenv("APP_RUN_FORCE", "true")
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "app", AutoEnv: true, EnvSeparator: ";"}
+	err := fs.BindStruct(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subOpt := &SubOpt{}
+	sub := &FlagSet{Name: "run"}
+	err = sub.BindStruct(subOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.AddSubCommand(sub)
+
+	err = fs.Parse([]string{"--host", "cli.com", "run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.Port != 8080 || opt.Host != "cli.com" || !subOpt.Force {
+		t.Errorf("value are incorrect: %+v", opt)
+	}
+
+	if fmt.Sprint(opt.Tags) != "[a b]" || fmt.Sprint(opt.Labels) != "map[k1:v1 k2:v2]" {
+		t.Errorf("value are incorrect: %+v", opt)
+	}
+
+	t.Setenv("APP_PORT", "port")
+	opt = &Opt{}
+	fs = &FlagSet{Name: "app"}
+	fs.BindStruct(opt)
+	if err = fs.Parse([]string{}); GetErrorCode(err) != ERROR_INVALID_VALUE {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestXFlagConfig(t *testing.T) {
+	type Opt struct {
+		Port   int               `xflag:"p,port,80"`
+		Host   string            `xflag:",host,localhost"`
+		Tags   []string          `xflag:",tag"`
+		Labels map[string]string `xflag:",label"`
+	}
+
+	type SubOpt struct {
+		Force bool   `xflag:"f,force"`
+		Name  string `xflag:",name"`
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.json": `{"port": 8080, "host": "json.com", "tag": ["a", "b"], "label": {"k": "v"},
+			"run": {"force": true}, "other": {}}`,
+		"app.ini": "port = 8080\nhost = \"ini.com\"\ntag = a\ntag = b\n\n[run]\nforce\n",
+		"bad.ini": "port = 8080\nundefined = 1\n",
+	}
+
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newFlagSet := func() (*FlagSet, *Opt, *SubOpt) {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "app"}
+		fs.BindStruct(opt)
+		fs.BindConfig("c", "config", "", "config file")
+
+		subOpt := &SubOpt{}
+		sub := &FlagSet{Name: "run"}
+		sub.BindStruct(subOpt)
+		fs.AddSubCommand(sub)
+		fs.AddSubCommand(&FlagSet{Name: "other"})
+		return fs, opt, subOpt
+	}
+
+	{
+		fs, opt, subOpt := newFlagSet()
+		err := fs.Parse([]string{"-c", filepath.Join(dir, "app.json"), "--host", "cli.com", "run"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opt.Port != 8080 || opt.Host != "cli.com" || !subOpt.Force {
+			t.Errorf("value are incorrect: %+v %+v", opt, subOpt)
+		}
+
+		if fmt.Sprint(opt.Tags) != "[a b]" || opt.Labels["k"] != "v" {
+			t.Errorf("value are incorrect: %+v", opt)
+		}
+	}
+
+	{
+		fs, opt, subOpt := newFlagSet()
+		err := fs.Parse([]string{"--config=" + filepath.Join(dir, "app.ini"), "run"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opt.Port != 8080 || opt.Host != "ini.com" || fmt.Sprint(opt.Tags) != "[a b]" || !subOpt.Force {
+			t.Errorf("value are incorrect: %+v %+v", opt, subOpt)
+		}
+	}
+
+	{
+		fs, _, _ := newFlagSet()
+		err := fs.Parse([]string{"-c", filepath.Join(dir, "bad.ini")})
+		if GetErrorCode(err) != ERROR_CONFIG || !strings.HasSuffix(err.Error(), "bad.ini:2: unknown key: undefined") {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		fs.AllowUnknownConfigKeys = true
+		if err = fs.Parse([]string{"-c", filepath.Join(dir, "bad.ini")}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	// config path from the environment
+	{
+		t.Setenv("APP_CONFIG", filepath.Join(dir, "app.json"))
+
+		fs, opt, _ := newFlagSet()
+		fs.AutoEnv = true
+		err := fs.Parse(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opt.Host != "json.com" || fs.Flag("host").Origin != filepath.Join(dir, "app.json")+":1" {
+			t.Errorf("config was not read: %+v %q", opt, fs.Flag("host").Origin)
+		}
+
+		if fs.Flag("config").Origin != "env APP_CONFIG" {
+			t.Errorf("unexpected origin: %q", fs.Flag("config").Origin)
+		}
+	}
+}
+
+type mapSource map[string]string
+
+func (s mapSource) Load(fs *FlagSet) error { return nil }
+
+func (s mapSource) Lookup(fs *FlagSet, flag *Flag) (values []string, origin string, ok bool) {
+	if v, ok := s[flag.Long]; ok {
+		return []string{v}, "map " + flag.Long, true
+	}
+	return nil, "", false
+}
+
+func TestXFlagSource(t *testing.T) {
+	type Opt struct {
+		Port    int    `xflag:"p,port,80"`
+		Host    string `xflag:",host" env:"XFLAG_TEST_HOST"`
+		User    string `xflag:",user"`
+		Timeout int    `xflag:",timeout"`
+		Retry   int    `xflag:",retry"`
+	}
+
+	path := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(path, []byte("{\n  \"host\": \"json.com\",\n  \"user\": \"admin\",\n  \"timeout\": 10\n}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XFLAG_TEST_HOST", "env.com")
+
+	opt := &Opt{}
+	fs := &FlagSet{Name: "app", EnableShowConfig: true}
+	fs.BindStruct(opt)
+	fs.BindConfig("c", "config", path, "config file")
+	fs.AddSource(mapSource{"user": "map", "retry": "3"})
+
+	err = fs.Parse([]string{"--timeout", "20", "--show-config"})
+	if GetErrorCode(err) != ERROR_SHOW_CONFIG_REQUESTED {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	origins := map[string]string{
+		"port":    OriginDefault,
+		"host":    "env XFLAG_TEST_HOST",
+		"user":    path + ":3",
+		"timeout": OriginCommandLine,
+		"retry":   "map retry",
+	}
+
+	for name, origin := range origins {
+		if fs.Flag(name).Origin != origin {
+			t.Errorf("unexpected origin of %s: %s", name, fs.Flag(name).Origin)
+		}
+	}
+
+	if opt.Port != 80 || opt.Host != "env.com" || opt.User != "admin" || opt.Timeout != 20 || opt.Retry != 3 {
+		t.Errorf("value are incorrect: %+v", opt)
+	}
+}
+
+func TestXFlagToArgs(t *testing.T) {
+	type Opt struct {
+		Verbose bool              `xflag:"v,verbose"`
+		Quiet   bool              `xflag:"q,quiet"`
+		Name    string            `xflag:"n,name"`
+		Level   int               `xflag:"l,level"`
+		Tags    []string          `xflag:",tag"`
+		Labels  map[string]string `xflag:",label"`
+		Timeout time.Duration     `xflag:",timeout,1s"`
+		Mode    string            `xflag:"m"`
+	}
+
+	type SubOpt struct {
+		Force bool    `xflag:"f,force"`
+		Ratio float64 `xflag:",ratio"`
+	}
+
+	newFlagSet := func() (*FlagSet, *Opt, *SubOpt) {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "app", AutoEnv: true}
+		fs.BindStruct(opt)
+
+		subOpt := &SubOpt{}
+		sub := &FlagSet{Name: "run"}
+		sub.BindStruct(subOpt)
+		fs.AddSubCommand(sub)
+		return fs, opt, subOpt
+	}
+
+	fs, opt, subOpt := newFlagSet()
+	err := fs.Parse([]string{
+		"-vq", "-n", "-x y", "-l3", "--tag", "a b", "--tag=-c", "--label", "k=v=w",
+		"run", "--force", "--ratio", "0.5", "--", "-arg", "arg",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := fs.ToArgs()
+	expected := `[--verbose --quiet --name=-x y --level=3 --tag=a b --tag=-c --label=k=v=w run --force --ratio=0.5 -- -arg arg]`
+	if fmt.Sprint(args) != expected {
+		t.Errorf("unexpected args: %q", args)
+	}
+
+	fs2, opt2, subOpt2 := newFlagSet()
+	err = fs2.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opt, opt2) || !reflect.DeepEqual(subOpt, subOpt2) {
+		t.Errorf("values were not reproduced: %+v %+v", opt2, subOpt2)
+	}
+
+	if fmt.Sprint(fs2.cmdSet["run"].Args()) != "[-arg arg]" {
+		t.Errorf("unexpected args: %v", fs2.cmdSet["run"].Args())
+	}
+
+	// false short-only booleans and short-only flags without EnvVar are
+	// left out
+	{
+		type ShortOpt struct {
+			D    bool   `xflag:"d,,true"`
+			Mode string `xflag:"m"`
+			Env  string `xflag:"e" env:"XFLAG_TEST_E"`
+		}
+
+		fs := &FlagSet{Name: "app"}
+		fs.BindStruct(&ShortOpt{})
+		if err := fs.Set("d", "false"); err != nil {
+			t.Fatal(err)
+		}
+		fs.Set("m", "x")
+		fs.Set("e", "y")
+
+		if args := fs.ToArgs(); fmt.Sprint(args) != "[-mx -ey]" {
+			t.Errorf("unexpected args: %q", args)
+		}
+
+		if env := fs.ToEnv("APP"); fmt.Sprint(env) != "[XFLAG_TEST_E=y]" {
+			t.Errorf("unexpected env: %q", env)
+		}
+	}
+
+	// an empty value of a short flag is a separate argument
+	{
+		fs, opt, _ := newFlagSet()
+		err := fs.Parse([]string{"-m", "", "-v"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		args := fs.ToArgs()
+		if fmt.Sprintf("%q", args) != `["--verbose" "-m" ""]` {
+			t.Errorf("unexpected args: %q", args)
+		}
+
+		fs2, opt2, _ := newFlagSet()
+		if err = fs2.Parse(args); err != nil || !reflect.DeepEqual(opt, opt2) {
+			t.Errorf("values were not reproduced: %v %+v", err, opt2)
+		}
+	}
+
+	env := fs.ToEnv("WORKER")
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		t.Setenv(kv[:i], kv[i+1:])
+	}
+
+	fs3, opt3, subOpt3 := newFlagSet()
+	fs3.EnvPrefix = "WORKER"
+	err = fs3.Parse([]string{"run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opt, opt3) || !reflect.DeepEqual(subOpt, subOpt3) {
+		t.Errorf("values were not reproduced: %v %+v %+v", env, opt3, subOpt3)
+	}
+}
+
+func TestXFlagConfigTemplate(t *testing.T) {
+	type Opt struct {
+		Port     int               `xflag:"p,port,80,listen port"`
+		Host     string            `xflag:",host,localhost,host name"`
+		Timeout  time.Duration     `xflag:",timeout,1m,request timeout"`
+		Tags     []string          `xflag:",tag,,tags"`
+		Labels   map[string]string `xflag:",label"`
+		Password string            `xflag:",password,,login password" secret:"true"`
+		Debug    bool              `xflag:",debug" hidden:"true"`
+	}
+
+	type SubOpt struct {
+		Force bool   `xflag:"f,force,true,force run"`
+		Name  string `xflag:",name,job"`
+	}
+
+	newFlagSet := func() (*FlagSet, *Opt, *SubOpt) {
+		opt := &Opt{}
+		fs := &FlagSet{Name: "app"}
+		fs.BindStruct(opt)
+		fs.BindConfig("c", "config", "", "config file")
+
+		subOpt := &SubOpt{}
+		sub := &FlagSet{Name: "run", Description: "run a job"}
+		sub.BindStruct(subOpt)
+		fs.AddSubCommand(sub)
+		return fs, opt, subOpt
+	}
+
+	dir := t.TempDir()
+	for _, format := range []ConfigFormat{ConfigJSON, ConfigINI} {
+		fs, _, _ := newFlagSet()
+
+		var b strings.Builder
+		if err := fs.WriteConfigTemplate(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		template := b.String()
+
+		for _, s := range []string{"listen port", "port", "80", "1m", "run a job", "force run"} {
+			if !strings.Contains(template, s) {
+				t.Errorf("%q is missing in template:\n%s", s, template)
+			}
+		}
+
+		for _, s := range []string{"password", "debug", "config"} {
+			if strings.Contains(template, s) {
+				t.Errorf("%q is written to template:\n%s", s, template)
+			}
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("app%d", format))
+		if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fs, opt, subOpt := newFlagSet()
+		fs.ConfigFormat = format
+		if err := fs.Parse([]string{"-c", path, "run"}); err != nil {
+			t.Fatalf("template is not readable: %v\n%s", err, template)
+		}
+
+		if opt.Port != 80 || opt.Host != "localhost" || opt.Timeout != time.Minute || !subOpt.Force || subOpt.Name != "job" {
+			t.Errorf("value are incorrect: %+v %+v", opt, subOpt)
+		}
+
+		// port follows its help comment
+		line := map[ConfigFormat]int{ConfigJSON: 3, ConfigINI: 2}[format]
+		if fs.Flag("--port").Origin != fmt.Sprintf("%s:%d", path, line) {
+			t.Errorf("unexpected origin: %s", fs.Flag("--port").Origin)
+		}
+	}
+
+	fs, _, _ := newFlagSet()
+	if err := fs.WriteConfigTemplate(io.Discard, ConfigAuto); GetErrorCode(err) != ERROR_CONFIG {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// a Value whose Get returns nil
+	{
+		var value nilValue
+		fs := &FlagSet{Name: "app"}
+		if err := fs.BindVar(&value, "", "nil", "", "nil value"); err != nil {
+			t.Fatal(err)
+		}
+
+		var b strings.Builder
+		fs.WriteConfigTemplate(&b, ConfigJSON)
+		fs.WriteConfigTemplate(&b, ConfigINI)
+		if !strings.Contains(b.String(), `"nil": null`) || !strings.Contains(b.String(), "; nil =") {
+			t.Errorf("unexpected template:\n%s", b.String())
+		}
+
+		if err := fs.Parse([]string{"--nil", "x"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if args := fs.ToArgs(); len(args) != 0 {
+			t.Errorf("unexpected args: %q", args)
+		}
+	}
+}
+
+type nilValue struct{}
+
+func (nilValue) Set(string) error { return nil }
+func (nilValue) Get() interface{} { return nil }
+
+func TestXFlagReset(t *testing.T) {
+	type Opt struct {
+		Port   int               `xflag:"p,port,80"`
+		Tags   []string          `xflag:",tag"`
+		Labels map[string]string `xflag:",label"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f,force"`
+	}
+
+	opt := &Opt{Tags: []string{"base"}, Labels: map[string]string{"k": "v"}}
+	subOpt := &SubOpt{}
+	fs := &FlagSet{Name: "app"}
+	fs.BindStruct(opt)
+	sub := &FlagSet{Name: "run"}
+	sub.BindStruct(subOpt)
+	fs.AddSubCommand(sub)
+
+	err := fs.Parse([]string{"-p", "8080", "--tag", "a", "--label", "x=y", "run", "-f", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(opt.Tags) != "[a]" || fmt.Sprint(opt.Labels) != "map[x:y]" || !subOpt.Force {
+		t.Fatalf("value are incorrect: %+v %+v", opt, subOpt)
+	}
+
+	fs.Reset()
+
+	if opt.Port != 0 || fmt.Sprint(opt.Tags) != "[base]" || fmt.Sprint(opt.Labels) != "map[k:v]" || subOpt.Force {
+		t.Errorf("values were not restored: %+v %+v", opt, subOpt)
+	}
+
+	if fs.Flag("--port").IsSet || fs.Flag("--port").Origin != "" || sub.Flag("-f").IsSet {
+		t.Errorf("flags are still set")
+	}
+
+	if fs.SubCommandName() != "" || len(sub.Args()) != 0 || fs.Result() != nil {
+		t.Errorf("sub-command state was not cleared: %q %v", fs.SubCommandName(), sub.Args())
+	}
+
+	err = fs.Parse([]string{"--tag", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.Port != 80 || fmt.Sprint(opt.Tags) != "[b]" || subOpt.Force || fs.SubCommandName() != "" {
+		t.Errorf("value are incorrect: %+v %+v", opt, subOpt)
+	}
+}
+
+func TestXFlagClone(t *testing.T) {
+	type Opt struct {
+		Port int      `xflag:"p,port,80"`
+		Tags []string `xflag:",tag" alias:"t"`
+		Host string   `xflag:",host"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f,force"`
+	}
+
+	path := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(path, []byte(`{"host": "json.com"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := &Opt{Tags: []string{"base"}}
+	subOpt := &SubOpt{}
+	fs := &FlagSet{Name: "app"}
+	fs.BindStruct(opt)
+	fs.BindConfig("c", "config", "", "config file")
+	fs.MutuallyExclusive("--port", "--host")
+	sub := &FlagSet{Name: "run"}
+	sub.BindStruct(subOpt)
+	fs.AddSubCommand(sub)
+
+	c := fs.Clone()
+
+	err = c.Parse([]string{"-c", path, "-t", "a", "run", "-f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(c.Flag("--tag").Value.Get()) != "[a]" || c.Flag("--host").Value.Get() != "json.com" {
+		t.Errorf("clone values are incorrect: %v %v", c.Flag("--tag").Value.Get(), c.Flag("--host").Value.Get())
+	}
+
+	csub := c.cmdSet["run"]
+	if c.SubCommandName() != "run" || csub.Flag("-f").Value.Get() != true || csub.parent != c {
+		t.Errorf("clone sub-command is incorrect")
+	}
+
+	// the original is not changed
+	if fmt.Sprint(opt.Tags) != "[base]" || opt.Host != "" || subOpt.Force || fs.SubCommandName() != "" {
+		t.Errorf("original values were changed: %+v %+v", opt, subOpt)
+	}
+
+	if fs.Flag("--tag").IsSet || fs.configPath != "" {
+		t.Errorf("original flags were changed")
+	}
+
+	// constraints refer to the flags of the clone
+	c.Reset()
+	err = c.Parse([]string{"-p", "1", "--host", "x"})
+	if GetErrorCode(err) != ERROR_CONSTRAINT {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestXFlagConcurrency(t *testing.T) {
+	type Opt struct {
+		Port int      `xflag:"p,port,80" min:"1"`
+		Tags []string `xflag:",tag"`
+	}
+
+	type SubOpt struct {
+		Force bool `xflag:"f,force"`
+	}
+
+	opt := &Opt{}
+	subOpt := &SubOpt{}
+	fs := &FlagSet{Name: "app"}
+	fs.BindStruct(opt)
+	sub := &FlagSet{Name: "run"}
+	sub.BindStruct(subOpt)
+	fs.AddSubCommand(sub)
+
+	// update
+	if err := fs.Set("port", "9090"); err != nil || opt.Port != 9090 || fs.Flag("-p").Origin != OriginSet {
+		t.Errorf("unexpected result: %v %d", err, opt.Port)
+	}
+
+	for _, value := range []string{"x", "0"} {
+		if err := fs.Set("--port", value); GetErrorCode(err) != ERROR_INVALID_VALUE || opt.Port != 9090 {
+			t.Errorf("invalid value was set: %v %d", err, opt.Port)
+		}
+	}
+
+	if err := fs.Set("prot", "1"); GetErrorCode(err) != ERROR_UNDEFINED_FLAG {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the snapshot does not share storage
+	fs.Set("tag", "a")
+	snapshot := fs.Snapshot()
+	fs.Set("tag", "b")
+	if snapshot["port"] != 9090 || fmt.Sprint(snapshot["tag"]) != "[a]" {
+		t.Errorf("unexpected snapshot: %v", snapshot)
+	}
+
+	// parse, lookup and update at once, run with -race
+	fs.Reset()
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		fs.Reset()
+		if err := fs.Parse([]string{"-p", "8080", "--tag", "a", "run", "-f"}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	run(func(i int) {
+		if err := sub.Set("force", "false"); err != nil {
+			t.Error(err)
+		}
+		if err := fs.Set("port", fmt.Sprint(i+1)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	run(func(i int) {
+		port := fs.Snapshot()["port"].(int)
+		if port < 0 || port > 100 && port != 8080 {
+			t.Errorf("unexpected port: %d", port)
+		}
+
+		if fs.Flag("--port") == nil || sub.Flag("-f") == nil {
+			t.Errorf("flag was not found")
+		}
+
+		fs.Visit(func(flag *Flag) error {
+			_ = flag.IsSet
+			_ = flag.Origin
+			return nil
+		})
+		sub.Snapshot()
+		fs.ToArgs()
+
+		if name := fs.SubCommandName(); name != "" && name != "run" {
+			t.Errorf("unexpected command: %s", name)
+		}
+		_ = fs.Args()
+		_ = sub.Args()
+		_ = fs.UnknownArgs()
+		_ = fs.Result()
+	})
+
+	// independent copies
+	for n := 0; n < 4; n++ {
+		port := fmt.Sprint(1000 + n)
+		run(func(i int) {
+			c := fs.Clone()
+			if err := c.Parse([]string{"-p", port, "run"}); err != nil {
+				t.Error(err)
+			}
+			if fmt.Sprint(c.Snapshot()["port"]) != port {
+				t.Errorf("unexpected port: %v", c.Snapshot()["port"])
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func TestXFlagFieldDefault(t *testing.T) {
+	type Opt struct {
+		Port    int               `xflag:"p,port"`
+		Host    string            `xflag:",host,tag.com"`
+		Timeout time.Duration     `xflag:",timeout"`
+		Tags    []string          `xflag:",tag"`
+		Labels  map[string]string `xflag:",label"`
+		User    string            `xflag:",user"`
+	}
+
+	opt := &Opt{
+		Port:    8080,
+		Host:    "field.com",
+		Timeout: time.Minute,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"k": "v"},
+	}
+
+	fs := &FlagSet{Name: "app"}
+	fs.BindStruct(opt)
+
+	defaults := map[string]string{
+		"port":    "8080",
+		"host":    "tag.com",
+		"timeout": "1m0s",
+		"tag":     "a,b",
+		"label":   "k=v",
+		"user":    "",
+	}
+
+	for name, def := range defaults {
+		if fs.Flag(name).DefValue != def {
+			t.Errorf("unexpected default of %s: %q", name, fs.Flag(name).DefValue)
+		}
+	}
+
+	err := fs.Parse([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.Port != 8080 || opt.Host != "tag.com" || opt.Timeout != time.Minute || fmt.Sprint(opt.Tags) != "[a b]" || len(opt.Labels) != 1 {
+		t.Errorf("value are incorrect: %+v", opt)
+	}
+
+	if fs.Flag("port").Origin != OriginDefault || fs.Flag("user").Origin != "" {
+		t.Errorf("unexpected origin: %q %q", fs.Flag("port").Origin, fs.Flag("user").Origin)
+	}
+
+	fs.Reset()
+	err = fs.Parse([]string{"-p", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.Port != 1 || fmt.Sprint(opt.Tags) != "[a b]" {
+		t.Errorf("value are incorrect: %+v", opt)
+	}
+
+	var b strings.Builder
+	fs.WriteConfigTemplate(&b, ConfigJSON)
+	if !strings.Contains(b.String(), `"tag": ["a", "b"]`) {
+		t.Errorf("unexpected template:\n%s", b.String())
+	}
+
+	// explicit values replace slices and maps of struct fields
+	type NamesOpt struct {
+		Names  []string          `xflag:"n,names"`
+		Labels map[string]string `xflag:",label" env:"XFLAG_TEST_LABEL"`
+	}
+
+	newFlagSet := func() (*FlagSet, *NamesOpt) {
+		opt := &NamesOpt{Names: []string{"a"}, Labels: map[string]string{"k": "v"}}
+		fs := &FlagSet{Name: "app"}
+		fs.BindStruct(opt)
+		return fs, opt
+	}
+
+	t.Setenv("XFLAG_TEST_LABEL", "x=y")
+
+	fs2, opt2 := newFlagSet()
+	if err = fs2.Parse([]string{"-n", "b", "-n", "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(opt2.Names) != "[b c]" || fmt.Sprint(opt2.Labels) != "map[x:y]" {
+		t.Errorf("value are incorrect: %+v", opt2)
+	}
+
+	fs3, opt3 := newFlagSet()
+	if err = fs3.Parse(fs2.ToArgs()); err != nil || !reflect.DeepEqual(opt2, opt3) {
+		t.Errorf("values were not reproduced: %v %+v", err, opt3)
+	}
+
+	fs4, opt4 := newFlagSet()
+	if err = fs4.Set("names", "d"); err != nil || fmt.Sprint(opt4.Names) != "[d]" {
+		t.Errorf("value are incorrect: %v %+v", err, opt4)
+	}
+}